@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Architecture names as returned by "uname -m", used in the URLs of many artifacts
+var artifactMachineArchs = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+}
+
+var (
+	// Line in checksum files in the format used by sha256sum: "<sha256>  <name>" or "<sha256> *<name>"
+	gnuChecksumLineExp = regexp.MustCompile(`^([0-9a-fA-F]{64}) [ *]?(\S.*)$`)
+	// Line in checksum files in the BSD format: "SHA256 (<name>) = <sha256>"
+	bsdChecksumLineExp = regexp.MustCompile(`^SHA256 \((.+)\) = ([0-9a-fA-F]{64})$`)
+)
+
+// artifactTemplateData is the data passed to the templates of the artifacts' URLs and names.
+type artifactTemplateData struct {
+	// Version of the app
+	Version string
+	// Architecture, such as "amd64"; empty for artifacts that don't list archs
+	Arch string
+	// Architecture as returned by "uname -m", such as "x86_64"
+	Machine string
+}
+
+// resolvedArtifact is an artifact with the templates rendered for a version and architecture.
+type resolvedArtifact struct {
+	Name         string
+	URL          string
+	ChecksumsURL string
+	Verify       bool
+}
+
+// Validate the artifact's properties.
+func (a App_Artifact) Validate() error {
+	if a.URL == "" {
+		return errors.New("property 'url' is required")
+	}
+	for _, arch := range a.Archs {
+		if _, ok := artifactMachineArchs[arch]; !ok {
+			return fmt.Errorf("invalid arch '%s'", arch)
+		}
+	}
+	// Render the templates with sample data to catch references to unknown fields too
+	sample := artifactTemplateData{Version: "1.0.0", Arch: "amd64", Machine: "x86_64"}
+	for prop, tpl := range map[string]string{"url": a.URL, "checksumsUrl": a.ChecksumsURL, "name": a.Name} {
+		t, err := template.New(prop).Option("missingkey=error").Parse(tpl)
+		if err == nil {
+			err = t.Execute(io.Discard, sample)
+		}
+		if err != nil {
+			return fmt.Errorf("property '%s' is not a valid template: %w", prop, err)
+		}
+	}
+	return nil
+}
+
+// Resolve renders the templates of the artifact for the version, returning one artifact per arch.
+func (a App_Artifact) Resolve(version string) ([]resolvedArtifact, error) {
+	err := a.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	archs := a.Archs
+	if len(archs) == 0 {
+		archs = []string{""}
+	}
+
+	res := make([]resolvedArtifact, 0, len(archs))
+	for _, arch := range archs {
+		data := artifactTemplateData{
+			Version: version,
+			Arch:    arch,
+			Machine: artifactMachineArchs[arch],
+		}
+		render := func(tpl string) (string, error) {
+			if tpl == "" {
+				return "", nil
+			}
+			t, err := template.New("").Option("missingkey=error").Parse(tpl)
+			if err != nil {
+				return "", err
+			}
+			var sb strings.Builder
+			err = t.Execute(&sb, data)
+			if err != nil {
+				return "", err
+			}
+			return sb.String(), nil
+		}
+
+		r := resolvedArtifact{Verify: a.Verify || a.ChecksumsURL == ""}
+		r.URL, err = render(a.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render 'url': %w", err)
+		}
+		r.ChecksumsURL, err = render(a.ChecksumsURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render 'checksumsUrl': %w", err)
+		}
+		r.Name, err = render(a.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render 'name': %w", err)
+		}
+		if r.Name == "" {
+			// Default to the name of the file in the URL
+			u, err := url.Parse(r.URL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid URL '%s': %w", r.URL, err)
+			}
+			r.Name = path.Base(u.Path)
+		}
+		if r.Name == "" || r.Name == "." || r.Name == "/" {
+			return nil, fmt.Errorf("could not determine the file name for URL '%s': set the 'name' property", r.URL)
+		}
+
+		res = append(res, r)
+	}
+
+	return res, nil
+}
+
+// AppChecksums returns the checksums of the app's artifacts for the version.
+// Checksums are read from the upstream checksum files if set, and computed by downloading the artifacts if there's no checksum file or if verification is enabled; when both are available, they must match.
+// The result is in the format used by sha256sum, with one line per artifact sorted by name.
+func (v *versionSources) AppChecksums(ctx context.Context, app *App, version string, log io.Writer) (string, error) {
+	artifacts := make([]resolvedArtifact, 0, len(app.Artifacts))
+	for i, a := range app.Artifacts {
+		resolved, err := a.Resolve(version)
+		if err != nil {
+			return "", permanentError{fmt.Errorf("invalid artifact %d: %w", i, err)}
+		}
+		artifacts = append(artifacts, resolved...)
+	}
+
+	// Checksum files are often shared by multiple artifacts, so they're downloaded once
+	checksumFiles := map[string]map[string]string{}
+
+	checksums := make(map[string]string, len(artifacts))
+	for _, a := range artifacts {
+		if _, ok := checksums[a.Name]; ok {
+			return "", fmt.Errorf("artifact name '%s' is used more than once", a.Name)
+		}
+
+		var upstream string
+		if a.ChecksumsURL != "" {
+			list, ok := checksumFiles[a.ChecksumsURL]
+			if !ok {
+				fmt.Fprintf(log, "  Reading checksums from %s\n", a.ChecksumsURL)
+				var err error
+				list, err = v.readChecksumFile(ctx, a.ChecksumsURL)
+				if err != nil {
+					return "", err
+				}
+				checksumFiles[a.ChecksumsURL] = list
+			}
+			var err error
+			upstream, err = lookupChecksum(list, a.Name)
+			if err != nil {
+				return "", fmt.Errorf("checksum file '%s': %w", a.ChecksumsURL, err)
+			}
+		}
+
+		var computed string
+		if a.Verify {
+			fmt.Fprintf(log, "  Downloading %s\n", a.URL)
+			var err error
+			computed, err = v.downloadChecksum(ctx, a.URL)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if upstream != "" && computed != "" && upstream != computed {
+			return "", fmt.Errorf("checksum of '%s' does not match: checksum file '%s' has %s, but the downloaded file has %s", a.Name, a.ChecksumsURL, upstream, computed)
+		}
+		checksums[a.Name] = upstream
+		if checksums[a.Name] == "" {
+			checksums[a.Name] = computed
+		}
+	}
+
+	return formatChecksums(checksums), nil
+}
+
+// appChecksumsKey returns a key that identifies how the checksums of the app are obtained, or an empty string if the app doesn't have checksums.
+// For the same version, apps with the same key have the same checksums.
+func appChecksumsKey(app *App) string {
+	switch {
+	case len(app.Artifacts) > 0:
+		j, _ := json.Marshal(app.Artifacts)
+		return "artifacts:" + string(j)
+	case app.Cmds != nil && app.Cmds.UpdateChecksums != "":
+		return "script:" + app.Cmds.UpdateChecksums
+	default:
+		return ""
+	}
+}
+
+// readChecksumFile downloads a checksum file and returns the checksums it contains, keyed by the path of the file as listed.
+// Lines in the format used by sha256sum and in the BSD format are supported, while other lines (such as signatures) are ignored.
+func (v *versionSources) readChecksumFile(ctx context.Context, fileURL string) (map[string]string, error) {
+	res := map[string]string{}
+	err := v.get(ctx, fileURL, 30*time.Second, func(body io.Reader) error {
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			var name, sum string
+			if match := gnuChecksumLineExp.FindStringSubmatch(line); match != nil {
+				sum, name = match[1], match[2]
+			} else if match := bsdChecksumLineExp.FindStringSubmatch(line); match != nil {
+				name, sum = match[1], match[2]
+			} else {
+				continue
+			}
+			res[strings.TrimPrefix(name, "./")] = strings.ToLower(sum)
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksum file: %w", err)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("checksum file '%s' does not contain any checksum", fileURL)
+	}
+	return res, nil
+}
+
+// lookupChecksum returns the checksum for the file from the list returned by readChecksumFile.
+// Files may be listed with a path, so if there's no exact match, the file whose path ends with the name is used; it's an error if there's more than one.
+func lookupChecksum(list map[string]string, name string) (string, error) {
+	sum, ok := list[name]
+	if ok {
+		return sum, nil
+	}
+
+	var found string
+	for file, fileSum := range list {
+		if !strings.HasSuffix(file, "/"+name) {
+			continue
+		}
+		if found != "" {
+			return "", fmt.Errorf("lists more than one file named '%s': '%s' and '%s'", name, min(found, file), max(found, file))
+		}
+		found, sum = file, fileSum
+	}
+	if found == "" {
+		return "", fmt.Errorf("does not contain a checksum for '%s'", name)
+	}
+	return sum, nil
+}
+
+// downloadChecksum downloads the file and returns its SHA-256 checksum.
+func (v *versionSources) downloadChecksum(ctx context.Context, fileURL string) (string, error) {
+	h := sha256.New()
+	err := v.get(ctx, fileURL, 10*time.Minute, func(body io.Reader) error {
+		_, err := io.Copy(h, body)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// formatChecksums returns the checksums in the format used by sha256sum, with one line per file sorted by name.
+func formatChecksums(checksums map[string]string) string {
+	names := slices.Sorted(maps.Keys(checksums))
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = checksums[name] + "  " + name
+	}
+	return strings.Join(lines, "\n")
+}
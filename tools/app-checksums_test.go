@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReadChecksumFile(t *testing.T) {
+	srv := newStubServer(t, map[string][]byte{
+		"/SHA256SUMS": []byte(`1111111111111111111111111111111111111111111111111111111111111111  tool_linux_amd64.tar.gz
+2222222222222222222222222222222222222222222222222222222222222222 *./bin/tool
+3333333333333333333333333333333333333333333333333333333333333333  linux/amd64/tool.rpm
+4444444444444444444444444444444444444444444444444444444444444444  linux/arm64/tool.rpm
+SHA256 (dist/tool.zip) = 5555555555555555555555555555555555555555555555555555555555555555
+-----BEGIN PGP SIGNATURE-----
+`),
+	})
+
+	sources := &versionSources{HTTPClient: srv.Client()}
+	list, err := sources.readChecksumFile(context.Background(), srv.URL+"/SHA256SUMS")
+	if err != nil {
+		t.Fatalf("failed to read checksum file: %v", err)
+	}
+	if len(list) != 5 {
+		t.Errorf("expected 5 checksums, got %v", list)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		err      string
+	}{
+		{name: "tool_linux_amd64.tar.gz", expected: "1111111111111111111111111111111111111111111111111111111111111111"},
+		{name: "tool", expected: "2222222222222222222222222222222222222222222222222222222222222222"},
+		{name: "bin/tool", expected: "2222222222222222222222222222222222222222222222222222222222222222"},
+		{name: "linux/amd64/tool.rpm", expected: "3333333333333333333333333333333333333333333333333333333333333333"},
+		{name: "arm64/tool.rpm", expected: "4444444444444444444444444444444444444444444444444444444444444444"},
+		{name: "tool.zip", expected: "5555555555555555555555555555555555555555555555555555555555555555"},
+		// Files with the same name in different folders must not be confused
+		{name: "tool.rpm", err: "lists more than one file named 'tool.rpm': 'linux/amd64/tool.rpm' and 'linux/arm64/tool.rpm'"},
+		{name: "amd64.tar.gz", err: "does not contain a checksum for 'amd64.tar.gz'"},
+		{name: "missing", err: "does not contain a checksum for 'missing'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, err := lookupChecksum(list, tt.name)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to look up checksum: %v", err)
+			}
+			if sum != tt.expected {
+				t.Errorf("wrong checksum %s", sum)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types/platform"
+)
+
+// Label added to images with the hash of the inputs used to build them
+const inputHashLabel = "io.github.italypaleale.bootc.input-hash"
+
+// Label added to images built on top of another container, with the digest of the parent image they were built on
+const parentDigestLabel = "io.github.italypaleale.bootc.parent-digest"
+
+// buildState contains the state shared by the builds of all containers in the same run.
+// It's safe for concurrent use.
+type buildState struct {
+	lock sync.Mutex
+	// Digests of the images built or reused in this run, keyed by container name
+	digests map[string]string
+	// IDs of the images built locally in this run without being pushed, keyed by container name
+	localImageIDs map[string]string
+	// Input hashes of containers, keyed by container name
+	inputHashes map[string]string
+	// Platforms of base images, keyed by image and digest
+	imagePlatforms map[string][]platform.Platform
+	// If set, the digests of parent images that were not built in this run are looked up in the repository
+	resolveParentDigest bool
+
+	// If set, digests are also saved to this file, to be shared with other runs
+	stateFile string
+	// Content of the state file
+	fileState buildStateFile
+	// Names of the images of containers, without tag, keyed by container name
+	imageNames map[string]string
+
+	// If set, the inputs used to build containers are recorded in this lockfile
+	lockFile string
+	// Content of the lockfile, including the entries from earlier runs
+	lockfile *buildLockfile
+	// If set, containers are built with the inputs recorded in this lockfile
+	fromLock *buildLockfile
+}
+
+// buildStateFile is the content of the file where the state is shared across runs.
+type buildStateFile struct {
+	// Digests of the images built or reused, keyed by image name without tag
+	Images map[string]string `json:"images"`
+}
+
+func newBuildState() *buildState {
+	return &buildState{
+		digests:        make(map[string]string),
+		localImageIDs:  make(map[string]string),
+		inputHashes:    make(map[string]string),
+		imagePlatforms: make(map[string][]platform.Platform),
+	}
+}
+
+// loadBuildState returns a new state that is shared with other runs through the file.
+// Digests of images found in the file are used for the containers in the config; the file doesn't need to exist.
+func loadBuildState(fileName string, flags *buildFlags, config *ConfigFile) (*buildState, error) {
+	s := newBuildState()
+	s.stateFile = fileName
+	s.fileState.Images = make(map[string]string)
+	s.imageNames = make(map[string]string, len(config.containersMap))
+	for name, containerConfig := range config.containersMap {
+		s.imageNames[name] = flags.buildImageName(containerConfig.ImageName)
+	}
+
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+	err = json.Unmarshal(data, &s.fileState)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing state file '%s': %w", fileName, err)
+	}
+	if s.fileState.Images == nil {
+		s.fileState.Images = make(map[string]string)
+	}
+
+	for name, imageName := range s.imageNames {
+		digest, ok := s.fileState.Images[imageName]
+		if ok && digest != "" {
+			s.digests[name] = digest
+		}
+	}
+
+	return s, nil
+}
+
+// SetDigest records the digest of the image for the container; an empty digest removes the one recorded earlier.
+// If the state is shared through a file, the file is updated too.
+func (s *buildState) SetDigest(containerName string, digest string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if digest != "" {
+		s.digests[containerName] = digest
+	} else {
+		delete(s.digests, containerName)
+	}
+
+	if s.stateFile == "" {
+		return nil
+	}
+	if digest != "" {
+		s.fileState.Images[s.imageNames[containerName]] = digest
+	} else {
+		delete(s.fileState.Images, s.imageNames[containerName])
+	}
+	data, err := json.MarshalIndent(s.fileState, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize state: %w", err)
+	}
+
+	err = writeFileAtomic(s.stateFile, data)
+	if err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	return nil
+}
+
+// RecordLock adds the entry for the container to the lockfile, if one is written.
+func (s *buildState) RecordLock(containerName string, entry *buildLockContainer) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.lockFile == "" {
+		return nil
+	}
+
+	s.lockfile.Containers[containerName] = entry
+	err := writeFileAtomic(s.lockFile, []byte(s.lockfile.String()))
+	if err != nil {
+		return fmt.Errorf("error writing lockfile: %w", err)
+	}
+	return nil
+}
+
+// Digest returns the digest of the image for the container, if it was built or reused in this run.
+func (s *buildState) Digest(containerName string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	digest, ok := s.digests[containerName]
+	return digest, ok
+}
+
+// SetLocalImageID records the ID of the image for the container, built locally in this run.
+func (s *buildState) SetLocalImageID(containerName string, id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.localImageIDs[containerName] = id
+}
+
+// LocalImageID returns the ID of the image for the container, if it was built locally in this run.
+func (s *buildState) LocalImageID(containerName string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	id, ok := s.localImageIDs[containerName]
+	return id, ok
+}
+
+// ImagePlatforms returns the platforms the image is available for, fetching them from the registry only once per run.
+func (s *buildState) ImagePlatforms(ctx context.Context, rc *regclient.RegClient, image string) ([]platform.Platform, error) {
+	s.lock.Lock()
+	platforms, ok := s.imagePlatforms[image]
+	s.lock.Unlock()
+	if ok {
+		return platforms, nil
+	}
+
+	platforms, err := getImagePlatforms(ctx, rc, image)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	s.imagePlatforms[image] = platforms
+	s.lock.Unlock()
+	return platforms, nil
+}
+
+// InputHash returns the hash of the inputs used to build the container.
+// The hash covers the composed Containerfile, the build args, the files in the build context, and the image the container is built on top of.
+// If build is nil, the build for the container is prepared first.
+func (s *buildState) InputHash(ctx context.Context, flags *buildFlags, config *ConfigFile, containerName string, build *containerBuild) (string, error) {
+	s.lock.Lock()
+	inputHash, ok := s.inputHashes[containerName]
+	s.lock.Unlock()
+	if ok {
+		return inputHash, nil
+	}
+
+	if build == nil {
+		var err error
+		build, err = prepareContainerBuild(flags, containerName, config, false)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// For the container this is built on top of, use the digest of its image if possible
+	var parentID string
+	parent := config.ParentContainer(containerName)
+	switch {
+	case parent != "" && build.ParentDigest != "":
+		parentID = build.ParentDigest
+	case parent != "":
+		var err error
+		parentID, err = s.parentImageID(ctx, flags, config, parent)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	inputHash, err := computeInputHash(build.Containerfile, build.HashedBuildArgs(), build.Config.BuildContext, parentID)
+	if err != nil {
+		return "", err
+	}
+
+	s.lock.Lock()
+	s.inputHashes[containerName] = inputHash
+	s.lock.Unlock()
+
+	return inputHash, nil
+}
+
+// parentImageID returns a value that identifies the image of a parent container, to be included in the input hash of its children.
+// This is the digest of the image if it was pushed or reused in this run, or the ID of the local image if it was built in this run without being pushed.
+// Otherwise, if resolveParentDigest is set, it's the digest of the image in the registry.
+// If neither is available, for example for local builds, the input hash of the parent is used instead.
+func (s *buildState) parentImageID(ctx context.Context, flags *buildFlags, config *ConfigFile, parent string) (string, error) {
+	digest, ok := s.Digest(parent)
+	if ok && digest != "" {
+		return digest, nil
+	}
+
+	// The image in the registry is older than one built locally in this run
+	id, ok := s.LocalImageID(parent)
+	if ok {
+		return id, nil
+	}
+
+	if s.resolveParentDigest {
+		parentConfig := config.containersMap[parent]
+		rc := regclient.New(regclient.WithDockerCreds())
+		digest, err := getImageDigest(ctx, rc, flags.buildImageNameTag(parentConfig.ImageName, "latest"))
+		if err == nil {
+			err = s.SetDigest(parent, digest)
+			if err != nil {
+				return "", err
+			}
+			return digest, nil
+		}
+	}
+
+	parentHash, err := s.InputHash(ctx, flags, config, parent, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute input hash for parent container '%s': %w", parent, err)
+	}
+	return parentHash, nil
+}
+
+// computeInputHash returns a deterministic hash of the inputs used to build a container.
+func computeInputHash(containerfile []byte, buildArgs []string, buildContext string, parentID string) (string, error) {
+	h := sha256.New()
+	writeHashField(h, "containerfile", containerfile)
+	for _, arg := range buildArgs {
+		writeHashField(h, "arg", []byte(arg))
+	}
+	writeHashField(h, "parent", []byte(parentID))
+
+	// Include the tree of files in the build context
+	// WalkDir visits files in lexical order, so the result is deterministic
+	err := filepath.WalkDir(buildContext, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(buildContext, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			writeHashField(h, "symlink "+rel, []byte(target))
+		case d.IsDir():
+			writeHashField(h, "dir "+rel, nil)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			sum, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			writeHashField(h, fmt.Sprintf("file %s %o", rel, info.Mode().Perm()), sum)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash build context '%s': %w", buildContext, err)
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeHashField writes a field to the hash, prefixed with its name and length so fields can't be confused with each other.
+func writeHashField(h hash.Hash, name string, value []byte) {
+	fmt.Fprintf(h, "%s %d\n", name, len(value))
+	h.Write(value)
+	h.Write([]byte{'\n'})
+}
+
+// hashFile returns the SHA-256 hash of the file's content.
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
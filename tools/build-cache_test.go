@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestFiles creates the files in dir, with paths relative to it.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(p), 0o755)
+		if err != nil {
+			t.Fatalf("failed to create folder for '%s': %v", name, err)
+		}
+		err = os.WriteFile(p, []byte(content), 0o644)
+		if err != nil {
+			t.Fatalf("failed to write '%s': %v", name, err)
+		}
+	}
+}
+
+const testBaseImagesConfig = `baseImages:
+  test-base:
+    image: example.com/test/base
+    tag: "1"
+    digest: sha256:0000000000000000000000000000000000000000000000000000000000000001
+`
+
+func TestInputHashParentImageID(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml":                      testBaseImagesConfig + "containers:\n  - base\n  - server\n",
+		"containers/base/container.yaml":   "imageName: base\nbaseImage: default\n",
+		"containers/base/Containerfile":    "FROM ${BASE_IMAGE}\nRUN echo base",
+		"containers/server/container.yaml": "imageName: server\nbaseImage: base\n",
+		"containers/server/Containerfile":  "FROM ${BASE_IMAGE}\nRUN echo server",
+	})
+
+	config, err := LoadConfigFile(dir, "config.yaml", "")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	flags := &buildFlags{
+		WorkDir:          dir,
+		DefaultBaseImage: "test-base",
+		Platform:         "podman",
+		Repository:       "example.com/repo",
+		Archs:            []string{"amd64"},
+	}
+
+	inputHash := func(resolveParentDigest bool, setup func(s *buildState)) string {
+		t.Helper()
+		s := newBuildState()
+		// The registry is only used when the parent's image was not built in this run
+		s.resolveParentDigest = resolveParentDigest
+		setup(s)
+		h, err := s.InputHash(context.Background(), flags, config, "server", nil)
+		if err != nil {
+			t.Fatalf("failed to compute input hash: %v", err)
+		}
+		return h
+	}
+
+	// Without an image for the parent, in local builds the input hash of the parent is used
+	noImage := inputHash(false, func(s *buildState) {})
+	if noImage != inputHash(false, func(s *buildState) {}) {
+		t.Error("input hash is not deterministic")
+	}
+
+	// A parent built locally in this run is identified by the ID of its local image
+	localA := inputHash(true, func(s *buildState) { s.SetLocalImageID("base", "sha256:aaaa") })
+	localB := inputHash(true, func(s *buildState) { s.SetLocalImageID("base", "sha256:bbbb") })
+	if localA == noImage || localA == localB {
+		t.Error("input hash does not depend on the local image of the parent")
+	}
+
+	// The digest of an image pushed or reused in this run has priority
+	pushed := inputHash(true, func(s *buildState) {
+		s.SetLocalImageID("base", "sha256:aaaa")
+		_ = s.SetDigest("base", "sha256:cccc")
+	})
+	if pushed == localA || pushed == noImage {
+		t.Error("input hash does not depend on the digest of the parent")
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// buildLockfile records the inputs used to build each container, so the build can be reproduced later.
+type buildLockfile struct {
+	// Containers, keyed by name
+	Containers map[string]*buildLockContainer `json:"containers"`
+}
+
+// buildLockContainer contains the inputs used to build a container, and the resulting image.
+type buildLockContainer struct {
+	// Name of the image, without tag
+	ImageName string `json:"imageName"`
+	// Digest of the image, if it was pushed or reused
+	Digest string `json:"digest,omitempty"`
+	// Hash of the inputs, as in the label of the image
+	InputHash string    `json:"inputHash"`
+	Archs     []string  `json:"archs"`
+	BuiltAt   time.Time `json:"builtAt"`
+	// Base image, for containers built on top of one of the base images in the config
+	BaseImage *buildLockBaseImage `json:"baseImage,omitempty"`
+	// Parent image, for containers built on top of another container
+	Parent *buildLockParent `json:"parent,omitempty"`
+	// Versions and checksums of the apps, keyed by app name
+	Apps map[string]buildLockApp `json:"apps,omitempty"`
+	// Hash of the composed Containerfile, including all apps
+	ContainerfileHash string `json:"containerfileHash"`
+}
+
+type buildLockBaseImage struct {
+	Name   string `json:"name"`
+	Image  string `json:"image"`
+	Digest string `json:"digest"`
+}
+
+type buildLockParent struct {
+	Container string `json:"container"`
+	// Name of the image, without tag
+	Image string `json:"image"`
+	// Digest of the image; empty if the parent was not pushed, in which case its local "latest" image was used
+	Digest string `json:"digest,omitempty"`
+}
+
+type buildLockApp struct {
+	Version   string `json:"version,omitempty"`
+	Checksums string `json:"checksums,omitempty"`
+}
+
+func (l buildLockfile) String() string {
+	j, _ := json.MarshalIndent(l, "", "  ")
+	return string(j)
+}
+
+// loadBuildLockfile reads a lockfile.
+// If allowMissing is true and the file doesn't exist, an empty lockfile is returned.
+func loadBuildLockfile(fileName string, allowMissing bool) (*buildLockfile, error) {
+	l := &buildLockfile{}
+	data, err := os.ReadFile(fileName)
+	if allowMissing && errors.Is(err, os.ErrNotExist) {
+		l.Containers = make(map[string]*buildLockContainer)
+		return l, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading lockfile: %w", err)
+	}
+
+	err = json.Unmarshal(data, l)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing lockfile '%s': %w", fileName, err)
+	}
+	if l.Containers == nil {
+		l.Containers = make(map[string]*buildLockContainer)
+	}
+
+	return l, nil
+}
+
+// newBuildLockContainer returns the entry in the lockfile for a container that was built or reused.
+func newBuildLockContainer(flags *buildFlags, config *ConfigFile, containerName string, build *containerBuild, result *buildResult, parentDigest string) *buildLockContainer {
+	entry := &buildLockContainer{
+		ImageName:         result.ImageName,
+		Digest:            result.Digest,
+		InputHash:         result.InputHash,
+		Archs:             build.Archs,
+		BuiltAt:           time.Now().UTC(),
+		Apps:              make(map[string]buildLockApp, len(build.Config.Apps)),
+		ContainerfileHash: hashContainerfile(build.Containerfile),
+	}
+
+	if parent := config.ParentContainer(containerName); parent != "" {
+		entry.Parent = &buildLockParent{
+			Container: parent,
+			Image:     build.ParentImage,
+			Digest:    parentDigest,
+		}
+	} else {
+		baseImageName := build.Config.BaseImage
+		if baseImageName == "default" {
+			baseImageName = flags.DefaultBaseImage
+		}
+		baseImage := config.BaseImages[baseImageName]
+		entry.BaseImage = &buildLockBaseImage{
+			Name:   baseImageName,
+			Image:  baseImage.Image,
+			Digest: baseImage.Digest,
+		}
+	}
+
+	for _, appName := range build.Config.Apps {
+		app := config.appsMap[appName]
+		if app == nil {
+			continue
+		}
+		entry.Apps[appName] = buildLockApp{
+			Version:   app.Version,
+			Checksums: app.Checksums,
+		}
+	}
+
+	return entry
+}
+
+// Apply returns a copy of the config that uses the base image and the app versions and checksums recorded for the container.
+// The config passed as argument is not modified.
+func (l buildLockfile) Apply(config *ConfigFile, containerName string, defaultBaseImage string) (*buildLockContainer, *ConfigFile, error) {
+	entry, ok := l.Containers[containerName]
+	if !ok || entry == nil {
+		return nil, nil, fmt.Errorf("container '%s' is not in the lockfile", containerName)
+	}
+	containerConfig, ok := config.containersMap[containerName]
+	if !ok {
+		return nil, nil, fmt.Errorf("container not found in configuration: %s", containerName)
+	}
+
+	res := *config
+
+	// Base image or parent container
+	parent := config.ParentContainer(containerName)
+	switch {
+	case entry.BaseImage != nil:
+		baseImageName := containerConfig.BaseImage
+		if baseImageName == "default" {
+			baseImageName = defaultBaseImage
+		}
+		if parent != "" || baseImageName != entry.BaseImage.Name {
+			return nil, nil, fmt.Errorf("container '%s' was built on top of base image '%s', but it's now built on top of '%s' (check --default-base-image)", containerName, entry.BaseImage.Name, baseImageName)
+		}
+		res.BaseImages = maps.Clone(config.BaseImages)
+		baseImage := res.BaseImages[baseImageName]
+		baseImage.Image = entry.BaseImage.Image
+		baseImage.Digest = entry.BaseImage.Digest
+		res.BaseImages[baseImageName] = baseImage
+	case entry.Parent != nil:
+		if parent != entry.Parent.Container {
+			return nil, nil, fmt.Errorf("container '%s' was built on top of container '%s', but it's now built on top of '%s'", containerName, entry.Parent.Container, containerConfig.BaseImage)
+		}
+	default:
+		return nil, nil, fmt.Errorf("entry for container '%s' in the lockfile has neither a base image nor a parent", containerName)
+	}
+
+	// Apps
+	res.appsMap = maps.Clone(config.appsMap)
+	for appName, lockedApp := range entry.Apps {
+		app, ok := res.appsMap[appName]
+		if !ok || app == nil {
+			return nil, nil, fmt.Errorf("app '%s' in the lockfile is not defined in the config", appName)
+		}
+		appCopy := *app
+		appCopy.Version = lockedApp.Version
+		appCopy.Checksums = lockedApp.Checksums
+		res.appsMap[appName] = &appCopy
+	}
+
+	return entry, &res, nil
+}
+
+// Verify returns an error if the build doesn't have the same Containerfile and architectures as the entry in the lockfile.
+func (e buildLockContainer) Verify(build *containerBuild) error {
+	if hashContainerfile(build.Containerfile) != e.ContainerfileHash {
+		return errors.New("the Containerfile is different from the one in the lockfile; check out the commit the lockfile was created from")
+	}
+	if !slices.Equal(build.Archs, e.Archs) {
+		return fmt.Errorf("the container was built for architectures %s, but is now built for %s (check --arch)", strings.Join(e.Archs, ", "), strings.Join(build.Archs, ", "))
+	}
+	return nil
+}
+
+// hashContainerfile returns the SHA-256 hash of the composed Containerfile.
+func hashContainerfile(containerfile []byte) string {
+	h := sha256.Sum256(containerfile)
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// writeFileAtomic writes the data to a temporary file first, then renames it, so the file is never partially written.
+func writeFileAtomic(fileName string, data []byte) error {
+	tmp := fileName + ".tmp"
+	err := os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testLockDigest = "sha256:9999999999999999999999999999999999999999999999999999999999999999"
+
+// newTestLockConfig returns a config with the "base" container, and "server" built on top of it, both including the "tool" app.
+func newTestLockConfig(t *testing.T) (*ConfigFile, *buildFlags) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml":                      testBaseImagesConfig + "containers:\n  - base\n  - server\napps:\n  - tool\n",
+		"containers/base/container.yaml":   "imageName: base\nbaseImage: default\napps:\n  - tool\n",
+		"containers/base/Containerfile":    "FROM ${BASE_IMAGE}\nRUN echo base",
+		"containers/server/container.yaml": "imageName: server\nbaseImage: base\napps:\n  - tool\n",
+		"containers/server/Containerfile":  "FROM ${BASE_IMAGE}\nRUN echo server",
+		"apps/tool/app.yaml":               "name: tool\nversion: 1.0.0\nchecksums: aaaa  tool.bin\n",
+		"apps/tool/Containerfile":          "ARG VERSION_TOOL\nRUN echo tool",
+	})
+
+	config, err := LoadConfigFile(dir, "config.yaml", "")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	flags := &buildFlags{
+		WorkDir:          dir,
+		DefaultBaseImage: "test-base",
+		Platform:         "podman",
+		Repository:       "example.com/repo",
+		Archs:            []string{"amd64", "arm64"},
+	}
+	return config, flags
+}
+
+// newTestLockEntry returns the entry in the lockfile for the container, built with the config.
+func newTestLockEntry(t *testing.T, flags *buildFlags, config *ConfigFile, containerName string, parentDigest string) (*buildLockContainer, *containerBuild) {
+	t.Helper()
+	build, err := prepareContainerBuild(flags, containerName, config, false)
+	if err != nil {
+		t.Fatalf("failed to prepare build: %v", err)
+	}
+	result := &buildResult{
+		Name:      containerName,
+		ImageName: flags.buildImageName(build.Config.ImageName),
+		Digest:    "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		InputHash: "hash-" + containerName,
+	}
+	return newBuildLockContainer(flags, config, containerName, build, result, parentDigest), build
+}
+
+func TestNewBuildLockContainer(t *testing.T) {
+	config, flags := newTestLockConfig(t)
+
+	base, build := newTestLockEntry(t, flags, config, "base", "")
+	if base.ImageName != "example.com/repo/base" || base.InputHash != "hash-base" {
+		t.Errorf("unexpected image in the entry: %+v", base)
+	}
+	expectedBaseImage := &buildLockBaseImage{
+		Name:   "test-base",
+		Image:  "example.com/test/base",
+		Digest: "sha256:0000000000000000000000000000000000000000000000000000000000000001",
+	}
+	if !reflect.DeepEqual(base.BaseImage, expectedBaseImage) || base.Parent != nil {
+		t.Errorf("got base image %+v and parent %+v", base.BaseImage, base.Parent)
+	}
+	if !reflect.DeepEqual(base.Apps, map[string]buildLockApp{"tool": {Version: "1.0.0", Checksums: "aaaa  tool.bin"}}) {
+		t.Errorf("unexpected apps %+v", base.Apps)
+	}
+	if base.ContainerfileHash != hashContainerfile(build.Containerfile) {
+		t.Errorf("wrong hash of the Containerfile '%s'", base.ContainerfileHash)
+	}
+
+	server, _ := newTestLockEntry(t, flags, config, "server", testLockDigest)
+	expectedParent := &buildLockParent{
+		Container: "base",
+		Image:     "example.com/repo/base",
+		Digest:    testLockDigest,
+	}
+	if !reflect.DeepEqual(server.Parent, expectedParent) || server.BaseImage != nil {
+		t.Errorf("got base image %+v and parent %+v", server.BaseImage, server.Parent)
+	}
+}
+
+func TestBuildLockfileRoundTrip(t *testing.T) {
+	config, flags := newTestLockConfig(t)
+	lockFile := filepath.Join(t.TempDir(), "build.lock.json")
+
+	// A lockfile that doesn't exist yet is allowed only when writing it
+	_, err := loadBuildLockfile(lockFile, false)
+	if err == nil {
+		t.Error("expected an error for a missing lockfile")
+	}
+
+	// Each run records the containers it builds
+	base, _ := newTestLockEntry(t, flags, config, "base", "")
+	server, _ := newTestLockEntry(t, flags, config, "server", testLockDigest)
+	for name, entry := range map[string]*buildLockContainer{"base": base, "server": server} {
+		state := newBuildState()
+		state.lockFile = lockFile
+		state.lockfile, err = loadBuildLockfile(lockFile, true)
+		if err != nil {
+			t.Fatalf("failed to load lockfile: %v", err)
+		}
+		err = state.RecordLock(name, entry)
+		if err != nil {
+			t.Fatalf("failed to record entry: %v", err)
+		}
+	}
+
+	// Entries from earlier runs are kept
+	loaded, err := loadBuildLockfile(lockFile, false)
+	if err != nil {
+		t.Fatalf("failed to load lockfile: %v", err)
+	}
+	expected := map[string]*buildLockContainer{"base": base, "server": server}
+	if !reflect.DeepEqual(loaded.Containers, expected) {
+		t.Errorf("lockfile does not match the recorded entries:\n%s", loaded)
+	}
+}
+
+func TestBuildLockfileApply(t *testing.T) {
+	config, flags := newTestLockConfig(t)
+
+	base, _ := newTestLockEntry(t, flags, config, "base", "")
+	base.BaseImage.Image = "example.com/test/base-mirror"
+	base.BaseImage.Digest = testLockDigest
+	base.Apps["tool"] = buildLockApp{Version: "0.9.0", Checksums: "cccc  tool.bin"}
+	server, _ := newTestLockEntry(t, flags, config, "server", testLockDigest)
+	lockfile := buildLockfile{Containers: map[string]*buildLockContainer{"base": base, "server": server}}
+
+	// The base image digest and the apps are overridden
+	entry, locked, err := lockfile.Apply(config, "base", "test-base")
+	if err != nil {
+		t.Fatalf("failed to apply lockfile: %v", err)
+	}
+	if entry != base {
+		t.Error("returned entry is not the one for the container")
+	}
+	build, err := prepareContainerBuild(flags, "base", locked, false)
+	if err != nil {
+		t.Fatalf("failed to prepare build: %v", err)
+	}
+	for name, expected := range map[string]string{
+		"BASE_IMAGE":     "example.com/test/base-mirror@" + testLockDigest,
+		"VERSION_TOOL":   "0.9.0",
+		"CHECKSUMS_TOOL": "cccc  tool.bin",
+	} {
+		value, _ := buildArgValues(build.BuildArgs, name)
+		if value != expected {
+			t.Errorf("got %s '%s', expected '%s'", name, value, expected)
+		}
+	}
+
+	// The original config is not modified
+	if config.BaseImages["test-base"].Digest == testLockDigest || config.appsMap["tool"].Version != "1.0.0" {
+		t.Error("original config was modified")
+	}
+
+	// For containers built on top of another, the parent is checked
+	_, _, err = lockfile.Apply(config, "server", "test-base")
+	if err != nil {
+		t.Errorf("failed to apply lockfile for the child: %v", err)
+	}
+
+	errTests := []struct {
+		name      string
+		container string
+		lockfile  buildLockfile
+		err       string
+	}{
+		{
+			name:      "container not in the lockfile",
+			container: "server",
+			lockfile:  buildLockfile{Containers: map[string]*buildLockContainer{"base": base}},
+			err:       "container 'server' is not in the lockfile",
+		},
+		{
+			name:      "different base image",
+			container: "base",
+			lockfile: buildLockfile{Containers: map[string]*buildLockContainer{
+				"base": {BaseImage: &buildLockBaseImage{Name: "other"}},
+			}},
+			err: "container 'base' was built on top of base image 'other', but it's now built on top of 'test-base' (check --default-base-image)",
+		},
+		{
+			name:      "different parent",
+			container: "server",
+			lockfile: buildLockfile{Containers: map[string]*buildLockContainer{
+				"server": {Parent: &buildLockParent{Container: "other"}},
+			}},
+			err: "container 'server' was built on top of container 'other', but it's now built on top of 'base'",
+		},
+		{
+			name:      "app not in the config",
+			container: "base",
+			lockfile: buildLockfile{Containers: map[string]*buildLockContainer{
+				"base": {BaseImage: base.BaseImage, Apps: map[string]buildLockApp{"nope": {Version: "1.0.0"}}},
+			}},
+			err: "app 'nope' in the lockfile is not defined in the config",
+		},
+		{
+			name:      "invalid entry",
+			container: "base",
+			lockfile:  buildLockfile{Containers: map[string]*buildLockContainer{"base": {}}},
+			err:       "entry for container 'base' in the lockfile has neither a base image nor a parent",
+		},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tt.lockfile.Apply(config, tt.container, "test-base")
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestBuildLockContainerVerify(t *testing.T) {
+	config, flags := newTestLockConfig(t)
+	entry, build := newTestLockEntry(t, flags, config, "base", "")
+
+	err := entry.Verify(build)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	changed := *build
+	changed.Containerfile = append([]byte("# changed\n"), build.Containerfile...)
+	err = entry.Verify(&changed)
+	if err == nil || !strings.Contains(err.Error(), "the Containerfile is different from the one in the lockfile") {
+		t.Errorf("expected an error for the Containerfile, got %v", err)
+	}
+
+	changed = *build
+	changed.Archs = []string{"amd64"}
+	err = entry.Verify(&changed)
+	if err == nil || err.Error() != "the container was built for architectures amd64, arm64, but is now built for amd64 (check --arch)" {
+		t.Errorf("expected an error for the architectures, got %v", err)
+	}
+}
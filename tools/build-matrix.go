@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Architectures that images can be built for, used when a base image doesn't restrict them
+var allArchs = []string{"amd64", "arm64"}
+
+// RootBaseImage returns the name of the base image that the chain of containers ending with the given container is built on.
+// If the chain is built on the "default" base image, defaultBaseImage is returned.
+func (c *ConfigFile) RootBaseImage(name string, defaultBaseImage string) (string, error) {
+	cur := c.RootContainer(name)
+	containerConfig, ok := c.containersMap[cur]
+	if !ok {
+		return "", fmt.Errorf("container not found in configuration: %s", cur)
+	}
+
+	baseImageName := containerConfig.BaseImage
+	if baseImageName == "default" {
+		baseImageName = defaultBaseImage
+	}
+	if _, ok := c.BaseImages[baseImageName]; !ok {
+		return "", fmt.Errorf("base image '%s' does not have a match in the list of base images or in other containers", baseImageName)
+	}
+
+	return baseImageName, nil
+}
+
+// ContainerBuildArchs returns the architectures the container with the given name is built for, out of the requested ones.
+// The result takes into account the architectures supported by the base image, by the container, and by all containers it's built on top of.
+// If the container must not be built, for example because it doesn't support the base image, the returned list is empty and skipReason contains the reason.
+func (c *ConfigFile) ContainerBuildArchs(name string, defaultBaseImage string, requested []string) (archs []string, skipReason string, err error) {
+	containerConfig, ok := c.containersMap[name]
+	if !ok {
+		return nil, "", fmt.Errorf("container not found in configuration: %s", name)
+	}
+
+	rootBaseImage, err := c.RootBaseImage(name, defaultBaseImage)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Start from the archs of the parent container, or of the base image
+	parent := c.ParentContainer(name)
+	if parent != "" {
+		archs, skipReason, err = c.ContainerBuildArchs(parent, defaultBaseImage, requested)
+		if err != nil {
+			return nil, "", err
+		}
+		if skipReason != "" {
+			return nil, fmt.Sprintf("parent container '%s' is skipped: %s", parent, skipReason), nil
+		}
+	} else {
+		archs = intersectArchs(requested, c.BaseImages[rootBaseImage].Archs)
+		if len(archs) == 0 {
+			return nil, fmt.Sprintf("base image '%s' supports architectures %s only", rootBaseImage, strings.Join(c.BaseImages[rootBaseImage].Archs, ", ")), nil
+		}
+	}
+
+	// Check the constraints on base images
+	if len(containerConfig.OnlyBaseImages) > 0 && !slices.Contains(containerConfig.OnlyBaseImages, rootBaseImage) {
+		return nil, fmt.Sprintf("container is built for base images %s only", strings.Join(containerConfig.OnlyBaseImages, ", ")), nil
+	}
+	if slices.Contains(containerConfig.ExcludeBaseImages, rootBaseImage) {
+		return nil, fmt.Sprintf("container is not built for base image '%s'", rootBaseImage), nil
+	}
+
+	// Restrict to the archs the container supports
+	archs = intersectArchs(archs, containerConfig.Archs)
+	if len(archs) == 0 {
+		return nil, fmt.Sprintf("container supports architectures %s only", strings.Join(containerConfig.Archs, ", ")), nil
+	}
+
+	return archs, "", nil
+}
+
+// intersectArchs returns the archs in requested that are also in supported, keeping the order of requested.
+// If supported is empty, all archs are supported.
+func intersectArchs(requested []string, supported []string) []string {
+	if len(supported) == 0 {
+		return requested
+	}
+
+	res := make([]string, 0, len(requested))
+	for _, a := range requested {
+		if slices.Contains(supported, a) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
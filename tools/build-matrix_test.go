@@ -0,0 +1,152 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestContainerBuildArchs(t *testing.T) {
+	newConfig := func() *ConfigFile {
+		config := newTestGraph([]string{"alma", "centos", "arm-only"}, [][2]string{
+			{"base", "default"},
+			{"server", "base"},
+			{"server-amd64", "server"},
+			{"alma-only", "base"},
+			{"no-centos", "base"},
+			{"on-arm", "arm-only"},
+			{"on-arm-amd64", "on-arm"},
+		})
+		config.BaseImages["arm-only"] = Config_BaseImages{Image: "example.com/arm-only", Archs: []string{"arm64"}}
+		config.containersMap["server-amd64"].Archs = []string{"amd64"}
+		config.containersMap["alma-only"].OnlyBaseImages = []string{"alma"}
+		config.containersMap["no-centos"].ExcludeBaseImages = []string{"centos"}
+		config.containersMap["on-arm-amd64"].Archs = []string{"amd64"}
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		container   string
+		defaultBase string
+		requested   []string
+		archs       []string
+		skipReason  string
+		err         string
+	}{
+		{name: "all requested archs", container: "base", defaultBase: "alma", requested: []string{"amd64", "arm64"}, archs: []string{"amd64", "arm64"}},
+		{name: "order of the requested archs", container: "base", defaultBase: "alma", requested: []string{"arm64", "amd64"}, archs: []string{"arm64", "amd64"}},
+		{name: "child inherits the archs of the parent", container: "server", defaultBase: "alma", requested: []string{"arm64"}, archs: []string{"arm64"}},
+		{name: "container archs intersected with the requested ones", container: "server-amd64", defaultBase: "alma", requested: []string{"amd64", "arm64"}, archs: []string{"amd64"}},
+		{
+			name: "empty intersection of the container archs", container: "server-amd64", defaultBase: "alma", requested: []string{"arm64"},
+			skipReason: "container supports architectures amd64 only",
+		},
+		{name: "base image archs", container: "on-arm", defaultBase: "alma", requested: []string{"amd64", "arm64"}, archs: []string{"arm64"}},
+		{
+			name: "empty intersection of the base image archs", container: "on-arm", defaultBase: "alma", requested: []string{"amd64"},
+			skipReason: "base image 'arm-only' supports architectures arm64 only",
+		},
+		{
+			name: "parent skipped", container: "on-arm-amd64", defaultBase: "alma", requested: []string{"amd64"},
+			skipReason: "parent container 'on-arm' is skipped: base image 'arm-only' supports architectures arm64 only",
+		},
+		{
+			name: "empty intersection with the archs of the base image of the parent", container: "on-arm-amd64", defaultBase: "alma", requested: []string{"amd64", "arm64"},
+			skipReason: "container supports architectures amd64 only",
+		},
+		{name: "only base images, matching", container: "alma-only", defaultBase: "alma", requested: []string{"amd64"}, archs: []string{"amd64"}},
+		{
+			name: "only base images, not matching", container: "alma-only", defaultBase: "centos", requested: []string{"amd64"},
+			skipReason: "container is built for base images alma only",
+		},
+		{name: "excluded base images, not matching", container: "no-centos", defaultBase: "alma", requested: []string{"amd64"}, archs: []string{"amd64"}},
+		{
+			name: "excluded base images, matching", container: "no-centos", defaultBase: "centos", requested: []string{"amd64"},
+			skipReason: "container is not built for base image 'centos'",
+		},
+		{name: "unknown default base image", container: "server", defaultBase: "nope", requested: []string{"amd64"}, err: "base image 'nope' does not have a match in the list of base images or in other containers"},
+		{name: "unknown container", container: "nope", defaultBase: "alma", requested: []string{"amd64"}, err: "container not found in configuration: nope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archs, skipReason, err := newConfig().ContainerBuildArchs(tt.container, tt.defaultBase, tt.requested)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if skipReason != tt.skipReason {
+				t.Errorf("got skip reason %q, expected %q", skipReason, tt.skipReason)
+			}
+			if !slices.Equal(archs, tt.archs) {
+				t.Errorf("got archs %v, expected %v", archs, tt.archs)
+			}
+		})
+	}
+}
+
+func TestRootBaseImage(t *testing.T) {
+	config := newTestGraph([]string{"alma", "centos"}, [][2]string{
+		{"base", "default"},
+		{"server", "base"},
+		{"pinned", "centos"},
+		{"on-pinned", "pinned"},
+		{"broken", "missing"},
+	})
+
+	tests := []struct {
+		container string
+		expected  string
+		err       string
+	}{
+		{container: "base", expected: "alma"},
+		{container: "server", expected: "alma"},
+		{container: "pinned", expected: "centos"},
+		{container: "on-pinned", expected: "centos"},
+		{container: "broken", err: "base image 'missing' does not have a match in the list of base images or in other containers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.container, func(t *testing.T) {
+			res, err := config.RootBaseImage(tt.container, "alma")
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res != tt.expected {
+				t.Errorf("got '%s', expected '%s'", res, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIntersectArchs(t *testing.T) {
+	tests := []struct {
+		requested []string
+		supported []string
+		expected  []string
+	}{
+		{requested: []string{"amd64", "arm64"}, supported: nil, expected: []string{"amd64", "arm64"}},
+		{requested: []string{"amd64", "arm64"}, supported: []string{"arm64"}, expected: []string{"arm64"}},
+		{requested: []string{"arm64", "amd64"}, supported: []string{"amd64", "arm64"}, expected: []string{"arm64", "amd64"}},
+		{requested: []string{"amd64"}, supported: []string{"arm64"}, expected: []string{}},
+		{requested: nil, supported: []string{"arm64"}, expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		res := intersectArchs(tt.requested, tt.supported)
+		if !slices.Equal(res, tt.expected) {
+			t.Errorf("intersectArchs(%v, %v) = %v, expected %v", tt.requested, tt.supported, res, tt.expected)
+		}
+	}
+}
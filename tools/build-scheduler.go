@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+type buildStatus int
+
+const (
+	buildStatusPending buildStatus = iota
+	buildStatusRunning
+	buildStatusDone
+	buildStatusSkipped
+	buildStatusUnchanged
+	buildStatusFailed
+	buildStatusCancelled
+)
+
+// buildContainerFunc builds a single container, writing all its console output to log.
+type buildContainerFunc func(ctx context.Context, name string, log io.Writer) (*buildResult, error)
+
+// buildContainersParallel builds the containers in flags.Containers, running up to flags.Jobs builds at the same time.
+func buildContainersParallel(ctx context.Context, flags *buildFlags, config *ConfigFile, state *buildState) error {
+	build := func(ctx context.Context, name string, log io.Writer) (*buildResult, error) {
+		return ProcessContainer(ctx, flags, name, config, state, log)
+	}
+	return scheduleBuilds(ctx, config, flags.Containers, flags.Jobs, os.Stdout, os.Stderr, build)
+}
+
+// scheduleBuilds builds the containers with build, running up to jobs builds at the same time.
+// A container is built as soon as the container it's built on top of (if part of the same run) has been built.
+// When a build fails, all containers that depend on it are cancelled, while builds of unrelated containers continue.
+// Results are printed to out, and the output of each build is written to console, with the name of the container as prefix.
+func scheduleBuilds(ctx context.Context, config *ConfigFile, containers []string, jobs int, out io.Writer, console io.Writer, build buildContainerFunc) error {
+	order, err := config.SortContainers(containers)
+	if err != nil {
+		return fmt.Errorf("failed to sort containers: %w", err)
+	}
+
+	// For each container, find the closest ancestor that is built in this run too
+	selected := make(map[string]bool, len(order))
+	for _, name := range order {
+		selected[name] = true
+	}
+	dependsOn := make(map[string]string, len(order))
+	for _, name := range order {
+		for parent := config.ParentContainer(name); parent != ""; parent = config.ParentContainer(parent) {
+			if selected[parent] {
+				dependsOn[name] = parent
+				break
+			}
+		}
+	}
+
+	// Lock shared by all writers to the console, so lines are not interleaved
+	var consoleLock sync.Mutex
+	logf := func(format string, a ...any) {
+		consoleLock.Lock()
+		defer consoleLock.Unlock()
+		fmt.Fprintf(console, format, a...)
+	}
+	prefixLen := 0
+	for _, name := range order {
+		prefixLen = max(prefixLen, len(name))
+	}
+
+	type buildOutcome struct {
+		name   string
+		result *buildResult
+		err    error
+	}
+	outcomes := make(chan buildOutcome)
+
+	status := make(map[string]buildStatus, len(order))
+	errs := make([]error, 0)
+	running := 0
+	for {
+		// Start all containers that are ready to be built, in order
+		for _, name := range order {
+			if status[name] != buildStatusPending {
+				continue
+			}
+
+			dep := dependsOn[name]
+			switch status[dep] {
+			case buildStatusFailed, buildStatusCancelled:
+				// Parent failed or was cancelled, so cancel this too
+				status[name] = buildStatusCancelled
+				logf("Cancelled building container '%s' because '%s' could not be built\n", name, dep)
+				continue
+			case buildStatusPending, buildStatusRunning:
+				if dep != "" {
+					// Not ready yet
+					continue
+				}
+			}
+
+			if running >= jobs || ctx.Err() != nil {
+				continue
+			}
+
+			status[name] = buildStatusRunning
+			running++
+			go func() {
+				log := newPrefixWriter(console, fmt.Sprintf("[%-*s] ", prefixLen, name), &consoleLock)
+				result, err := build(ctx, name, log)
+				_ = log.Flush()
+				outcomes <- buildOutcome{name: name, result: result, err: err}
+			}()
+		}
+
+		if running == 0 {
+			break
+		}
+
+		// Wait for the next build to complete
+		o := <-outcomes
+		running--
+		if o.err != nil {
+			status[o.name] = buildStatusFailed
+			errs = append(errs, fmt.Errorf("failed to process container '%s': %w", o.name, o.err))
+			logf("Failed building container '%s': %v\n", o.name, o.err)
+			continue
+		}
+
+		status[o.name] = buildStatusDone
+		switch {
+		case o.result.Unchanged:
+			status[o.name] = buildStatusUnchanged
+		case o.result.Skipped:
+			status[o.name] = buildStatusSkipped
+		}
+		consoleLock.Lock()
+		fmt.Fprintln(out, o.result)
+		consoleLock.Unlock()
+	}
+
+	// Print a summary
+	summary := map[buildStatus][]string{}
+	for _, name := range order {
+		summary[status[name]] = append(summary[status[name]], name)
+	}
+	logf("Build summary:\n")
+	for _, s := range []struct {
+		status buildStatus
+		label  string
+	}{
+		{buildStatusDone, "Built"},
+		{buildStatusSkipped, "Skipped"},
+		{buildStatusUnchanged, "Unchanged"},
+		{buildStatusFailed, "Failed"},
+		{buildStatusCancelled, "Cancelled"},
+		{buildStatusPending, "Not started"},
+	} {
+		if len(summary[s.status]) > 0 {
+			logf("  %s: %s\n", s.label, strings.Join(summary[s.status], ", "))
+		}
+	}
+
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+
+	return errors.Join(errs...)
+}
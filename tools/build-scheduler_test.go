@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubBuilds records the builds started by scheduleBuilds.
+type stubBuilds struct {
+	config *ConfigFile
+	// Containers whose build fails
+	fail map[string]bool
+	// How long each build takes
+	delay time.Duration
+
+	lock    sync.Mutex
+	started []string
+	done    map[string]bool
+	running int
+	// Highest number of builds running at the same time
+	maxRunning int
+	// Errors found while building, such as a container built before its parent
+	errs []error
+}
+
+func newStubBuilds(config *ConfigFile, fail ...string) *stubBuilds {
+	s := &stubBuilds{
+		config: config,
+		fail:   map[string]bool{},
+		done:   map[string]bool{},
+		delay:  10 * time.Millisecond,
+	}
+	for _, f := range fail {
+		s.fail[f] = true
+	}
+	return s
+}
+
+func (s *stubBuilds) build(ctx context.Context, name string, log io.Writer) (*buildResult, error) {
+	s.lock.Lock()
+	s.started = append(s.started, name)
+	s.running++
+	s.maxRunning = max(s.maxRunning, s.running)
+	for parent := s.config.ParentContainer(name); parent != ""; parent = s.config.ParentContainer(parent) {
+		if !s.done[parent] && slices.Contains(s.started, parent) {
+			s.errs = append(s.errs, fmt.Errorf("container '%s' started before its parent '%s' was built", name, parent))
+		}
+	}
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.running--
+		s.lock.Unlock()
+	}()
+
+	// Write lines in multiple chunks, to make sure they're not interleaved with others
+	for i := range 3 {
+		fmt.Fprintf(log, "line %d ", i)
+		time.Sleep(time.Millisecond)
+		fmt.Fprintf(log, "of %s\n", name)
+	}
+
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if s.fail[name] {
+		return nil, errors.New("stub build failed")
+	}
+
+	s.lock.Lock()
+	s.done[name] = true
+	s.lock.Unlock()
+	return &buildResult{Name: name, ImageName: "example.com/" + name}, nil
+}
+
+// Two branches, "a" and "b", where "a" has two children
+var testSchedulerGraph = [][2]string{
+	{"a", "default"},
+	{"b", "default"},
+	{"a1", "a"},
+	{"a2", "a"},
+	{"a1x", "a1"},
+	{"b1", "b"},
+	{"b1x", "b1"},
+}
+
+func TestScheduleBuilds(t *testing.T) {
+	all := []string{"a", "b", "a1", "a2", "a1x", "b1", "b1x"}
+
+	tests := []struct {
+		name string
+		jobs int
+		fail []string
+		// Containers that must be built, and that must not be started
+		built      []string
+		notStarted []string
+		err        string
+	}{
+		{
+			name:  "all built",
+			jobs:  3,
+			built: all,
+		},
+		{
+			name:       "failed sibling",
+			jobs:       3,
+			fail:       []string{"a1"},
+			built:      []string{"a", "a2", "b", "b1", "b1x"},
+			notStarted: []string{"a1x"},
+			err:        "failed to process container 'a1': stub build failed",
+		},
+		{
+			name:       "failed sibling with one job",
+			jobs:       1,
+			fail:       []string{"a1"},
+			built:      []string{"a", "a2", "b", "b1", "b1x"},
+			notStarted: []string{"a1x"},
+			err:        "failed to process container 'a1': stub build failed",
+		},
+		{
+			name:       "failed root",
+			jobs:       2,
+			fail:       []string{"b"},
+			built:      []string{"a", "a1", "a2", "a1x"},
+			notStarted: []string{"b1", "b1x"},
+			err:        "failed to process container 'b': stub build failed",
+		},
+		{
+			name:       "failures in both branches",
+			jobs:       4,
+			fail:       []string{"a", "b1"},
+			built:      []string{"b"},
+			notStarted: []string{"a1", "a2", "a1x", "b1x"},
+			err:        "failed to process container 'b1': stub build failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestGraph([]string{"default"}, testSchedulerGraph)
+			stub := newStubBuilds(config, tt.fail...)
+			var out, console bytes.Buffer
+
+			err := scheduleBuilds(context.Background(), config, all, tt.jobs, &out, &console, stub.build)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error containing %q, got %v", tt.err, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			for _, e := range stub.errs {
+				t.Error(e)
+			}
+			for _, name := range tt.built {
+				if !stub.done[name] {
+					t.Errorf("container '%s' was not built", name)
+				}
+				if !strings.Contains(out.String(), `"name": "`+name+`"`) {
+					t.Errorf("result for container '%s' was not printed", name)
+				}
+			}
+			for _, name := range tt.notStarted {
+				if slices.Contains(stub.started, name) {
+					t.Errorf("container '%s' was started", name)
+				}
+				if !strings.Contains(console.String(), "Cancelled building container '"+name+"'") {
+					t.Errorf("cancellation of container '%s' was not reported", name)
+				}
+			}
+			if stub.maxRunning > tt.jobs {
+				t.Errorf("%d builds ran at the same time, with %d jobs", stub.maxRunning, tt.jobs)
+			}
+		})
+	}
+}
+
+func TestScheduleBuildsJobsLimit(t *testing.T) {
+	// Independent containers can all be built at the same time
+	containers := make([][2]string, 8)
+	names := make([]string, 8)
+	for i := range containers {
+		names[i] = fmt.Sprintf("c%d", i)
+		containers[i] = [2]string{names[i], "default"}
+	}
+	config := newTestGraph([]string{"default"}, containers)
+
+	for _, jobs := range []int{1, 3, 8} {
+		t.Run(fmt.Sprintf("%d jobs", jobs), func(t *testing.T) {
+			stub := newStubBuilds(config)
+			stub.delay = 30 * time.Millisecond
+			err := scheduleBuilds(context.Background(), config, names, jobs, io.Discard, io.Discard, stub.build)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(stub.done) != len(names) {
+				t.Errorf("built %d containers, expected %d", len(stub.done), len(names))
+			}
+			if stub.maxRunning != jobs {
+				t.Errorf("%d builds ran at the same time, expected %d", stub.maxRunning, jobs)
+			}
+		})
+	}
+}
+
+// Line written by stubBuilds, with the prefix added by scheduleBuilds
+var buildLineExp = regexp.MustCompile(`^\[([a-z0-9]+) *\] line [0-9] of ([a-z0-9]+)\n$`)
+
+func TestScheduleBuildsOutput(t *testing.T) {
+	config := newTestGraph([]string{"default"}, testSchedulerGraph)
+	stub := newStubBuilds(config)
+	var console bytes.Buffer
+
+	err := scheduleBuilds(context.Background(), config, []string{"a", "b", "a1", "a2", "b1"}, 5, io.Discard, &console, stub.build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Each line written by a build is complete and has the prefix of its container, padded to the longest name
+	count := map[string]int{}
+	for line := range strings.Lines(console.String()) {
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		m := buildLineExp.FindStringSubmatch(line)
+		if m == nil || m[1] != m[2] || !strings.HasPrefix(line, fmt.Sprintf("[%-2s] ", m[1])) {
+			t.Errorf("line is interleaved or has the wrong prefix: %q", line)
+			continue
+		}
+		count[m[1]]++
+	}
+	for _, name := range []string{"a", "b", "a1", "a2", "b1"} {
+		if count[name] != 3 {
+			t.Errorf("found %d lines for container '%s', expected 3", count[name], name)
+		}
+	}
+	if !strings.Contains(console.String(), "Build summary:\n  Built: a, b, a1, a2, b1\n") {
+		t.Errorf("summary not found in output:\n%s", console.String())
+	}
+}
+
+func TestScheduleBuildsCancelled(t *testing.T) {
+	config := newTestGraph([]string{"default"}, testSchedulerGraph)
+	stub := newStubBuilds(config)
+	stub.delay = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := scheduleBuilds(ctx, config, []string{"a", "b", "a1", "b1"}, 1, io.Discard, io.Discard, stub.build)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("running build was not stopped")
+	}
+	// Only the first build was started, and no other build is started after the context is cancelled
+	if !slices.Equal(stub.started, []string{"a"}) {
+		t.Errorf("started builds %v, expected only 'a'", stub.started)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var lock sync.Mutex
+	w := newPrefixWriter(&out, "[x] ", &lock)
+
+	_, _ = w.Write([]byte("first "))
+	if out.Len() != 0 {
+		t.Errorf("partial line was written: %q", out.String())
+	}
+	_, _ = w.Write([]byte("line\nsecond line\nthird"))
+	_, _ = w.Write([]byte(" line"))
+	err := w.Flush()
+	if err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	expected := "[x] first line\n[x] second line\n[x] third line\n"
+	if out.String() != expected {
+		t.Errorf("got %q, expected %q", out.String(), expected)
+	}
+
+	// Flushing again doesn't write anything
+	_ = w.Flush()
+	if out.String() != expected {
+		t.Errorf("got %q after flushing again", out.String())
+	}
+}
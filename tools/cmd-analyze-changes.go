@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -17,6 +19,7 @@ func init() {
 		Short: "Analyze changed files and determine which containers need rebuilding",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Validate flags
+			flags.UseGit = cmd.Flags().Changed("base-ref") || cmd.Flags().Changed("head-ref")
 			err := flags.Validate()
 			if err != nil {
 				return err
@@ -34,34 +37,74 @@ func init() {
 				return fmt.Errorf("failed to analyze changes: %w", err)
 			}
 
-			// Print result as JSON
-			fmt.Println(result)
+			// Print the result
+			switch flags.Output {
+			case "tree":
+				fmt.Print(result.Tree(config))
+			default:
+				fmt.Println(result)
+			}
 
 			return nil
 		},
 	}
 
 	analyzeChangesCmd.Flags().StringVarP(&flags.WorkDir, "work-dir", "w", ".", "Working directory, containing the config files, the apps, and containers")
-	analyzeChangesCmd.Flags().StringSliceVarP(&flags.ChangedFiles, "changed-files", "f", []string{}, "List of changed files (relative to work-dir)")
+	analyzeChangesCmd.Flags().StringSliceVarP(&flags.ChangedFiles, "changed-files", "f", []string{}, "List of changed files (relative to work-dir); a container is rebuilt when one of the files used to build it changes")
+	analyzeChangesCmd.Flags().StringVar(&flags.BaseRef, "base-ref", "", "Git revision to compare against; if empty or not found in the repository, all containers are rebuilt")
+	analyzeChangesCmd.Flags().StringVar(&flags.HeadRef, "head-ref", "HEAD", "Git revision with the changes, used with --base-ref")
+	analyzeChangesCmd.Flags().StringVarP(&flags.DefaultBaseImage, "default-base-image", "b", "", "Name of the default base image the containers are built with; if empty, changes to any base image affect containers built on the default one")
+
+	analyzeChangesCmd.Flags().BoolVar(&flags.Explain, "explain", false, "Include the reasons why each container must be rebuilt")
+	analyzeChangesCmd.Flags().StringVarP(&flags.Output, "output", "o", "json", "Output format: \"json\" or \"tree\"; the tree always includes the reasons why each container must be rebuilt")
 
 	rootCmd.AddCommand(analyzeChangesCmd)
 }
 
 type analyzeChangesFlags struct {
-	WorkDir      string
-	ChangedFiles []string
+	WorkDir          string
+	ChangedFiles     []string
+	BaseRef          string
+	HeadRef          string
+	DefaultBaseImage string
+	Explain          bool
+	Output           string
+
+	// If true, changed files are read from git using BaseRef and HeadRef
+	UseGit bool
 }
 
 func (f *analyzeChangesFlags) Validate() error {
 	if f.WorkDir == "" {
 		return fmt.Errorf("flag --work-dir must not be empty")
 	}
+	switch f.Output {
+	case "json", "tree":
+		// Valid
+	default:
+		return fmt.Errorf("flag --output must be one of: json, tree")
+	}
+	// The tree output always includes the reasons
+	if f.Output == "tree" {
+		f.Explain = true
+	}
+	if f.UseGit {
+		if len(f.ChangedFiles) > 0 {
+			return fmt.Errorf("flag --changed-files cannot be used together with --base-ref and --head-ref")
+		}
+		if f.HeadRef == "" {
+			return fmt.Errorf("flag --head-ref must not be empty")
+		}
+	}
 	return nil
 }
 
 type analyzeChangesResult struct {
-	RebuildAll bool     `json:"rebuildAll"`
-	Containers []string `json:"containers"`
+	RebuildAll       bool     `json:"rebuildAll"`
+	RebuildAllReason string   `json:"rebuildAllReason,omitempty"`
+	Containers       []string `json:"containers"`
+	// Reasons why each container must be rebuilt; only included when explaining the result
+	Reasons map[string][]rebuildReason `json:"reasons,omitempty"`
 }
 
 func (r analyzeChangesResult) String() string {
@@ -71,125 +114,374 @@ func (r analyzeChangesResult) String() string {
 	return string(j)
 }
 
+// Tree returns the result as a human-readable tree, where each container is listed under the container it's built on top of.
+func (r analyzeChangesResult) Tree(config *ConfigFile) string {
+	var sb strings.Builder
+
+	if r.RebuildAll {
+		sb.WriteString("All containers must be rebuilt")
+		if r.RebuildAllReason != "" {
+			sb.WriteString(": " + r.RebuildAllReason)
+		}
+		sb.WriteString("\n")
+		return sb.String()
+	}
+	if len(r.Containers) == 0 {
+		sb.WriteString("No containers need to be rebuilt\n")
+		return sb.String()
+	}
+
+	// Group containers by their parent; containers whose parent is not rebuilt are at the top level
+	// Because the list is sorted in topological order, children keep the build order
+	selected := make(map[string]bool, len(r.Containers))
+	for _, name := range r.Containers {
+		selected[name] = true
+	}
+	roots := make([]string, 0)
+	children := make(map[string][]string)
+	for _, name := range r.Containers {
+		parent := config.ParentContainer(name)
+		if parent != "" && selected[parent] {
+			children[parent] = append(children[parent], name)
+		} else {
+			roots = append(roots, name)
+		}
+	}
+
+	var writeNode func(name string, prefix string, connector string, childPrefix string)
+	writeNode = func(name string, prefix string, connector string, childPrefix string) {
+		sb.WriteString(prefix + connector + name)
+		reasons := make([]string, len(r.Reasons[name]))
+		for i, reason := range r.Reasons[name] {
+			reasons[i] = reason.String()
+		}
+		if len(reasons) > 0 {
+			sb.WriteString(" (" + strings.Join(reasons, "; ") + ")")
+		}
+		sb.WriteString("\n")
+
+		for i, child := range children[name] {
+			if i == len(children[name])-1 {
+				writeNode(child, prefix+childPrefix, "└── ", "    ")
+			} else {
+				writeNode(child, prefix+childPrefix, "├── ", "│   ")
+			}
+		}
+	}
+	for _, name := range roots {
+		writeNode(name, "", "", "")
+	}
+
+	return sb.String()
+}
+
+// rebuildReasonKind is the kind of reason why a container must be rebuilt.
+type rebuildReasonKind string
+
+const (
+	// A file used to build the container changed
+	rebuildReasonFileChanged rebuildReasonKind = "fileChanged"
+	// An app included in the container changed
+	rebuildReasonAppChanged rebuildReasonKind = "appChanged"
+	// The container the container is built on top of is rebuilt
+	rebuildReasonParentRebuilt rebuildReasonKind = "parentRebuilt"
+	// The base image the container is built on top of changed in the config file
+	rebuildReasonBaseImageChanged rebuildReasonKind = "baseImageChanged"
+	// The container was added to the config file
+	rebuildReasonContainerAdded rebuildReasonKind = "containerAdded"
+)
+
+// rebuildReason is a reason why a container must be rebuilt.
+type rebuildReason struct {
+	Kind rebuildReasonKind `json:"kind"`
+	// Name of the app, parent container, or base image, depending on the kind
+	Name string `json:"name,omitempty"`
+	// Path of the changed file, if any
+	File string `json:"file,omitempty"`
+}
+
+func (r rebuildReason) String() string {
+	var msg string
+	switch r.Kind {
+	case rebuildReasonFileChanged:
+		msg = "file changed"
+	case rebuildReasonAppChanged:
+		msg = fmt.Sprintf("app '%s' changed", r.Name)
+	case rebuildReasonParentRebuilt:
+		msg = fmt.Sprintf("parent '%s' rebuilt", r.Name)
+	case rebuildReasonBaseImageChanged:
+		msg = fmt.Sprintf("base image '%s' changed", r.Name)
+	case rebuildReasonContainerAdded:
+		msg = "container added"
+	default:
+		msg = string(r.Kind)
+	}
+	if r.File != "" {
+		msg += ": " + r.File
+	}
+	return msg
+}
+
+// rebuildReasons contains the reasons why each container must be rebuilt.
+type rebuildReasons map[string][]rebuildReason
+
+// Add records a reason why the container must be rebuilt, ignoring duplicates.
+func (r rebuildReasons) Add(containerName string, reason rebuildReason) {
+	if slices.Contains(r[containerName], reason) {
+		return
+	}
+	r[containerName] = append(r[containerName], reason)
+}
+
 func analyzeChanges(flags *analyzeChangesFlags, config *ConfigFile) (*analyzeChangesResult, error) {
 	result := &analyzeChangesResult{
 		RebuildAll: false,
 		Containers: []string{},
 	}
-
-	// If no changed files provided, rebuild all
-	if len(flags.ChangedFiles) == 0 {
+	rebuildAll := func(reason string) (*analyzeChangesResult, error) {
 		result.RebuildAll = true
+		result.RebuildAllReason = reason
 		return result, nil
 	}
 
-	// Track which containers need rebuilding
-	containersToRebuild := make(map[string]bool)
-	// Track which apps have changed
-	changedApps := make(map[string]bool)
-	// Track if base images config changed
-	baseImagesChanged := false
+	var changedFiles []changedFile
+	if flags.UseGit {
+		// Read the list of changed files from git
+		var (
+			ok  bool
+			err error
+		)
+		changedFiles, ok, err = gitChangedFilesForWorkDir(flags.WorkDir, flags.BaseRef, flags.HeadRef)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return rebuildAll("base revision is empty or not found in the repository")
+		}
+	} else {
+		if len(flags.ChangedFiles) == 0 {
+			// If no changed files provided, rebuild all
+			return rebuildAll("no list of changed files")
+		}
 
-	for _, file := range flags.ChangedFiles {
-		// Normalize the path
-		file = filepath.Clean(file)
+		// Paths are relative to the work dir
+		changedFiles = make([]changedFile, len(flags.ChangedFiles))
+		for i, file := range flags.ChangedFiles {
+			path, err := filepath.Abs(filepath.Join(flags.WorkDir, file))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get absolute path of changed file '%s': %w", file, err)
+			}
+			changedFiles[i] = changedFile{
+				Path: path,
+				Name: filepath.ToSlash(filepath.Clean(file)),
+			}
+		}
+	}
 
-		// Check if it's the config file
-		if strings.HasSuffix(file, "config.yaml") || strings.HasSuffix(file, "config.override.yaml") {
-			// If base images section might have changed, we need to be conservative
-			// For simplicity, we'll check if the file contains base image definitions
-			// In a real implementation, we'd parse and compare the actual changes
-			baseImagesChanged = true
-			continue
+	// Get the files and folders used to build each container
+	allContainers, err := config.AllContainers()
+	if err != nil {
+		return nil, err
+	}
+	inputs := make(map[string][]containerInput, len(allContainers))
+	for _, containerName := range allContainers {
+		inputs[containerName], err = config.ContainerInputs(containerName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get inputs for container '%s': %w", containerName, err)
 		}
+	}
 
-		// Check if it's a container file
-		if strings.Contains(file, "/containers/") {
-			parts := strings.Split(file, "/containers/")
-			if len(parts) >= 2 {
-				containerPath := strings.Split(parts[1], "/")
-				if len(containerPath) > 0 {
-					containerFolderName := containerPath[0]
-					// Find the container by folder name
-					for _, containerConfig := range config.containersMap {
-						// Check if this container's directory matches
-						containerDir := filepath.Base(filepath.Dir(containerConfig.SavePath))
-						if containerDir == containerFolderName {
-							containersToRebuild[containerConfig.ImageName] = true
-							break
-						}
-					}
-				}
-			}
+	// Paths of the config files
+	configFiles := make([]string, 0, 2)
+	for _, name := range []string{"config.yaml", "config.override.yaml"} {
+		path, err := filepath.Abs(filepath.Join(flags.WorkDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path of config file: %w", err)
+		}
+		configFiles = append(configFiles, path)
+	}
+
+	// Track which containers need rebuilding, and why
+	containersToRebuild := rebuildReasons{}
+	// Track if the config file changed
+	configChanged := false
+
+	for _, file := range changedFiles {
+		// Check if it's the config file
+		if slices.Contains(configFiles, file.Path) {
+			// What changed in the config file is determined below
+			configChanged = true
 			continue
 		}
 
-		// Check if it's an app file
-		if strings.Contains(file, "/apps/") {
-			parts := strings.Split(file, "/apps/")
-			if len(parts) >= 2 {
-				appPath := strings.Split(parts[1], "/")
-				if len(appPath) > 0 {
-					appName := appPath[0]
-					changedApps[appName] = true
+		// Check if it's an input of a container
+		matched := false
+		for _, containerName := range allContainers {
+			for _, input := range inputs[containerName] {
+				if !input.Matches(file.Path) {
+					continue
+				}
+				matched = true
+				if input.App != "" {
+					containersToRebuild.Add(containerName, rebuildReason{
+						Kind: rebuildReasonAppChanged,
+						Name: input.App,
+						File: file.Name,
+					})
+				} else {
+					containersToRebuild.Add(containerName, rebuildReason{
+						Kind: rebuildReasonFileChanged,
+						File: file.Name,
+					})
 				}
 			}
+		}
+		if matched {
 			continue
 		}
 
 		// Check if it's in the tools directory (build system change)
-		if strings.Contains(file, "tools/") && strings.HasSuffix(file, ".go") {
-			result.RebuildAll = true
-			return result, nil
+		if strings.Contains(file.Name, "tools/") && strings.HasSuffix(file.Name, ".go") {
+			return rebuildAll("build tools changed: " + file.Name)
 		}
 
 		// Check if it's the workflow file itself
-		if strings.Contains(file, ".github/workflows/build-containers.yaml") {
-			result.RebuildAll = true
-			return result, nil
+		if strings.Contains(file.Name, ".github/workflows/build-containers.yaml") {
+			return rebuildAll("build workflow changed: " + file.Name)
 		}
 	}
 
-	// If base images changed, rebuild all containers
-	if baseImagesChanged {
-		result.RebuildAll = true
-		return result, nil
-	}
+	// If the config file changed, rebuild the containers affected by the changes
+	if configChanged {
+		// Without the previous version of the config file, we can't know what changed, so we need to be conservative
+		if !flags.UseGit {
+			return rebuildAll("config file changed")
+		}
 
-	// If apps changed, find all containers that use those apps
-	if len(changedApps) > 0 {
-		for containerName, containerConfig := range config.containersMap {
-			for _, app := range containerConfig.Apps {
-				if changedApps[app] {
-					containersToRebuild[containerName] = true
-					break
-				}
+		diff, err := diffConfigFiles(flags.WorkDir, flags.BaseRef, flags.HeadRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare config files: %w", err)
+		}
+		if diff.RebuildAllReason != "" {
+			fmt.Fprintf(os.Stderr, "Rebuilding all containers: %s\n", diff.RebuildAllReason)
+			return rebuildAll(diff.RebuildAllReason)
+		}
+
+		// Containers built on top of base images that changed
+		// Only the root of each chain is recorded here, the others are rebuilt because their parent is
+		for _, containerName := range config.ContainersOnBaseImages(diff.BaseImages, flags.DefaultBaseImage) {
+			if config.ParentContainer(containerName) != "" {
+				continue
+			}
+			baseImage := config.containersMap[containerName].BaseImage
+			if baseImage == "default" && flags.DefaultBaseImage != "" {
+				baseImage = flags.DefaultBaseImage
 			}
+			containersToRebuild.Add(containerName, rebuildReason{
+				Kind: rebuildReasonBaseImageChanged,
+				Name: baseImage,
+			})
 		}
-	}
 
-	// Build dependency graph: if a container changes, all containers that depend on it need to rebuild
-	if len(containersToRebuild) > 0 {
-		changed := true
-		for changed {
-			changed = false
-			for containerName, containerConfig := range config.containersMap {
-				if containersToRebuild[containerName] {
-					continue
-				}
-				// Check if this container depends on a changed container
-				if containersToRebuild[containerConfig.BaseImage] {
-					containersToRebuild[containerName] = true
-					changed = true
-				}
+		// Containers that were added to the config file
+		for _, folder := range diff.AddedContainers {
+			containerConfig := config.ContainerByFolder(folder)
+			if containerConfig != nil {
+				containersToRebuild.Add(containerConfig.ImageName, rebuildReason{
+					Kind: rebuildReasonContainerAdded,
+				})
 			}
 		}
 	}
 
-	// Convert map to list
-	for containerName := range containersToRebuild {
-		result.Containers = append(result.Containers, containerName)
+	// If a container changes, all containers that depend on it need to rebuild
+	// Because the list is sorted, changes propagate down the whole chain
+	for _, containerName := range allContainers {
+		parent := config.ParentContainer(containerName)
+		if parent != "" && len(containersToRebuild[parent]) > 0 {
+			containersToRebuild.Add(containerName, rebuildReason{
+				Kind: rebuildReasonParentRebuilt,
+				Name: parent,
+			})
+		}
+	}
+
+	// List the containers in the order they must be built
+	for _, containerName := range allContainers {
+		if len(containersToRebuild[containerName]) > 0 {
+			result.Containers = append(result.Containers, containerName)
+		}
+	}
+
+	if flags.Explain {
+		result.Reasons = containersToRebuild
 	}
 
 	return result, nil
 }
+
+// changedFile is a file that changed.
+type changedFile struct {
+	// Absolute path of the file
+	Path string
+	// Path of the file as reported by git or passed in the flags, used in messages
+	Name string
+}
+
+// gitChangedFilesForWorkDir returns the files changed between the two revisions.
+// Files are included even if they are outside of the work dir, because they can be part of a container's build context, or be part of the tools and the build workflow.
+// Names of the returned files are relative to the root of the repository.
+// If baseRef is empty or cannot be found in the repository, the second return value is false.
+func gitChangedFilesForWorkDir(workDir string, baseRef string, headRef string) ([]changedFile, bool, error) {
+	if baseRef == "" {
+		fmt.Fprint(os.Stderr, "No base revision provided\n")
+		return nil, false, nil
+	}
+	if !gitRevisionExists(workDir, baseRef) {
+		fmt.Fprintf(os.Stderr, "Base revision '%s' not found in the repository\n", baseRef)
+		return nil, false, nil
+	}
+
+	// Get the path of the root of the repository relative to the work dir
+	root, err := gitRepoRoot(workDir)
+	if err != nil {
+		return nil, false, err
+	}
+	workDirAbs, err := filepath.Abs(workDir)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get absolute path of work dir: %w", err)
+	}
+	// Resolve symlinks, because git returns the real path to the root
+	workDirReal, err := filepath.EvalSymlinks(workDirAbs)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to resolve path of work dir: %w", err)
+	}
+	rootRel, err := filepath.Rel(workDirReal, root)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get path of the repository relative to the work dir: %w", err)
+	}
+	// This is the same as root, but without resolving symlinks, so paths can be compared with the ones in the configuration
+	rootAbs := filepath.Join(workDirAbs, rootRel)
+
+	changes, err := gitChangedFiles(workDir, baseRef, headRef)
+	if err != nil {
+		return nil, false, err
+	}
+
+	res := make([]changedFile, 0, len(changes))
+	for _, c := range changes {
+		// For renames, include both the old and the new path
+		for _, p := range []string{c.OldPath, c.Path} {
+			if p == "" {
+				continue
+			}
+			res = append(res, changedFile{
+				Path: filepath.Join(rootAbs, filepath.FromSlash(p)),
+				Name: p,
+			})
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Changed files between '%s' and '%s': %d\n", baseRef, headRef, len(res))
+	return res, true, nil
+}
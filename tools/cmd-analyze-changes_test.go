@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAnalyzeChangesGolden(t *testing.T) {
+	root := t.TempDir()
+	git := newTestGitRepo(t, root)
+	workDir := filepath.Join(root, "el10")
+
+	const (
+		digestA = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+		digestB = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+	)
+	configFile := func(centosDigest string, containers string) string {
+		return "baseImages:\n" +
+			"  alma:\n    image: example.com/alma\n    tag: \"10\"\n    digest: " + digestA + "\n" +
+			"  centos:\n    image: example.com/centos\n    tag: \"10\"\n    digest: " + centosDigest + "\n" +
+			"containers:\n" + containers +
+			"apps:\n  - tool\n"
+	}
+	const containers = "  - base\n  - server\n  - desktop\n  - pinned\n  - on-pinned\n  - other\n"
+	files := map[string]string{
+		"config.yaml":                         configFile(digestA, containers),
+		"apps/tool/app.yaml":                  "name: tool\nversion: 1.0.0\n",
+		"apps/tool/Containerfile":             "RUN echo tool\n",
+		"containers/base/container.yaml":      "imageName: base\nbaseImage: default\napps:\n  - tool\n",
+		"containers/server/container.yaml":    "imageName: server\nbaseImage: base\n",
+		"containers/server/files/motd":        "hello\n",
+		"containers/desktop/container.yaml":   "imageName: desktop\nbaseImage: base\n",
+		"containers/pinned/container.yaml":    "imageName: pinned\nbaseImage: centos\n",
+		"containers/on-pinned/container.yaml": "imageName: on-pinned\nbaseImage: pinned\n",
+		"containers/other/container.yaml":     "imageName: other\nbaseImage: default\n",
+		"containers/extra/container.yaml":     "imageName: extra\nbaseImage: other\n",
+	}
+	for _, c := range []string{"base", "server", "desktop", "pinned", "on-pinned", "other", "extra"} {
+		files["containers/"+c+"/Containerfile"] = "FROM ${BASE_IMAGE}\n"
+	}
+	writeTestFiles(t, workDir, files)
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "base")
+	base := git("rev-parse", "HEAD")
+
+	// Bump the digest of a base image, add a container, and change an app and a file of a container
+	writeTestFiles(t, workDir, map[string]string{
+		"config.yaml":                  configFile(digestB, containers+"  - extra\n"),
+		"apps/tool/Containerfile":      "RUN echo tool 2\n",
+		"containers/server/files/motd": "hello world\n",
+	})
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "head")
+
+	config, err := LoadConfigFile(workDir, "config.yaml", "config.override.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	flags := &analyzeChangesFlags{
+		WorkDir:          workDir,
+		BaseRef:          base,
+		HeadRef:          "HEAD",
+		DefaultBaseImage: "alma",
+		Explain:          true,
+		Output:           "json",
+		UseGit:           true,
+	}
+	result, err := analyzeChanges(flags, config)
+	if err != nil {
+		t.Fatalf("failed to analyze changes: %v", err)
+	}
+
+	checkGolden(t, filepath.Join("testdata", "analyze-changes", "result.golden.json"), []byte(result.String()+"\n"))
+	checkGolden(t, filepath.Join("testdata", "analyze-changes", "tree.golden.txt"), []byte(result.Tree(config)))
+}
+
+func TestAnalyzeChangesTreeRebuildAll(t *testing.T) {
+	config := newTestGraph([]string{"default"}, [][2]string{{"a", "default"}})
+
+	tests := []struct {
+		result   analyzeChangesResult
+		expected string
+	}{
+		{
+			result:   analyzeChangesResult{RebuildAll: true, RebuildAllReason: "no list of changed files"},
+			expected: "All containers must be rebuilt: no list of changed files\n",
+		},
+		{
+			result:   analyzeChangesResult{Containers: []string{}},
+			expected: "No containers need to be rebuilt\n",
+		},
+	}
+
+	for _, tt := range tests {
+		res := tt.result.Tree(config)
+		if res != tt.expected {
+			t.Errorf("got %q, expected %q", res, tt.expected)
+		}
+	}
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
-	"path/filepath"
 	"slices"
 	"strings"
 	"time"
@@ -22,14 +25,14 @@ func init() {
 	buildCmd := &cobra.Command{
 		Use:   "build",
 		Short: "Build container image(s)",
-		Args:  cobra.MinimumNArgs(1),
+		Args:  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Validate flags
+			flags.Containers = args
 			err := flags.Validate()
 			if err != nil {
 				return err
 			}
-			flags.Containers = args
 
 			// Load the config file
 			config, err := LoadConfigFile(flags.WorkDir, "config.yaml", "config.override.yaml")
@@ -37,12 +40,56 @@ func init() {
 				return fmt.Errorf("failed to load config file: %w", err)
 			}
 
+			// Resolve the list of containers to build
+			switch {
+			case flags.All:
+				flags.Containers, err = config.AllContainers()
+			case flags.From != "":
+				flags.Containers, err = config.ContainerWithDependents(flags.From)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to resolve containers to build: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Containers to build: %s\n", strings.Join(flags.Containers, ", "))
+
+			// State shared by the builds, which can be loaded from a file shared with other runs
+			state := newBuildState()
+			if flags.StateFile != "" {
+				state, err = loadBuildState(flags.StateFile, flags, config)
+				if err != nil {
+					return fmt.Errorf("failed to load build state: %w", err)
+				}
+			}
+			// When the registry is used, children are built on top of the image of the parent in the registry
+			state.resolveParentDigest = flags.Push || flags.SkipUnchanged
+			if flags.LockFile != "" {
+				state.lockFile = flags.LockFile
+				state.lockfile, err = loadBuildLockfile(flags.LockFile, true)
+				if err != nil {
+					return fmt.Errorf("failed to load lockfile: %w", err)
+				}
+			}
+			if flags.FromLock != "" {
+				state.fromLock, err = loadBuildLockfile(flags.FromLock, false)
+				if err != nil {
+					return fmt.Errorf("failed to load lockfile to build from: %w", err)
+				}
+			}
+
+			// Build containers in parallel if requested
+			if flags.Jobs > 1 {
+				return buildContainersParallel(cmd.Context(), flags, config, state)
+			}
+
 			// Process each container in order
 			for _, container := range flags.Containers {
-				err = ProcessContainer(flags, container, config)
+				result, err := ProcessContainer(cmd.Context(), flags, container, config, state, os.Stderr)
 				if err != nil {
 					return fmt.Errorf("failed to process container '%s': %w", container, err)
 				}
+
+				// Print the result
+				fmt.Println(result)
 			}
 
 			return nil
@@ -55,7 +102,15 @@ func init() {
 	buildCmd.Flags().StringVarP(&flags.WorkDir, "work-dir", "w", ".", "Working directory, containing the config files, the apps, and containers")
 	buildCmd.Flags().StringVarP(&flags.DefaultBaseImage, "default-base-image", "b", "", "Name of the default base image to use, from the versions file")
 	buildCmd.Flags().StringSliceVarP(&flags.Tags, "tag", "t", []string{"latest"}, "Tag(s) for the image, for pushing ('latest' is added automatically)")
-	buildCmd.Flags().StringSliceVarP(&flags.Archs, "arch", "a", []string{"amd64"}, "Architecture(s) for building the image")
+	buildCmd.Flags().StringSliceVarP(&flags.Archs, "arch", "a", []string{"amd64"}, "Architecture(s) for building the image; containers are built only for the archs they support")
+	buildCmd.Flags().BoolVar(&flags.All, "all", false, "Build all containers, in dependency order")
+	buildCmd.Flags().StringVar(&flags.From, "from", "", "Build the given container and all containers built on top of it, in dependency order")
+	buildCmd.Flags().IntVarP(&flags.Jobs, "jobs", "j", 1, "Number of containers to build in parallel")
+
+	buildCmd.Flags().BoolVar(&flags.SkipUnchanged, "skip-unchanged", false, "Skip building containers when the image in the repository was built with the same inputs")
+	buildCmd.Flags().StringVar(&flags.StateFile, "state-file", "", "File where the digests of the images are saved, so containers built on top of them in later runs use the same images")
+	buildCmd.Flags().StringVar(&flags.LockFile, "lock-file", "", "If set, record the inputs used to build each container in this lockfile; no lockfile is written by default, so local builds don't leave one behind or merge entries from unrelated builds into it")
+	buildCmd.Flags().StringVar(&flags.FromLock, "from-lock", "", "Build the containers with the inputs recorded in this lockfile, such as base image digests and app versions, instead of the ones in the config")
 
 	rootCmd.AddCommand(buildCmd)
 }
@@ -68,6 +123,13 @@ type buildFlags struct {
 	Repository       string
 	Tags             []string
 	Archs            []string
+	All              bool
+	From             string
+	Jobs             int
+	SkipUnchanged    bool
+	StateFile        string
+	LockFile         string
+	FromLock         string
 
 	Containers []string
 }
@@ -86,6 +148,29 @@ func (f *buildFlags) Validate() error {
 	if f.WorkDir == "" {
 		return errors.New("flag --work-dir must not be empty")
 	}
+	if f.Jobs < 1 {
+		return errors.New("flag --jobs must be at least 1")
+	}
+
+	// Containers can be selected with only one of: positional arguments, --all, or --from
+	selectors := 0
+	if len(f.Containers) > 0 {
+		selectors++
+	}
+	if f.All {
+		selectors++
+	}
+	if f.From != "" {
+		selectors++
+	}
+	switch selectors {
+	case 0:
+		return errors.New("containers to build must be passed as arguments, or using --all or --from")
+	case 1:
+		// All good
+	default:
+		return errors.New("containers to build must be passed as arguments, or using --all or --from, but not in combination")
+	}
 
 	switch f.Platform {
 	case "podman", "docker":
@@ -113,27 +198,93 @@ func (f buildFlags) buildImageName(imageName string) string {
 	return path.Join(f.Repository, imageName)
 }
 
-func ProcessContainer(flags *buildFlags, containerName string, config *ConfigFile) error {
-	var result buildResult
+// containerBuild contains everything needed to build a container.
+type containerBuild struct {
+	// Configuration for the container
+	Config *ContainerConfig
+	// Architectures to build for
+	Archs []string
+	// Name of the image of the parent container without tag, if the container is built on top of another container
+	ParentImage string
+	// Digest of the parent image to build on, if fixed in advance, such as by a lockfile
+	ParentDigest string
+	// If set, the container must not be built, for the reason included
+	SkipReason string
+	// Temporary name and tag of the manifest that is built
+	ManifestNameTag string
+	// Arguments for the build command
+	BuildArgs []string
+	// Effective Containerfile, including all apps
+	Containerfile []byte
+}
 
-	basePath := filepath.Join(config.Folders.ContainersDir, containerName)
-	fmt.Fprintf(os.Stderr, "Building container '%s': %s\n", containerName, basePath)
+// HashedBuildArgs returns the build args that affect the content of the image, to be included in the input hash.
+// This excludes the name of the temporary manifest and the path to the build context, which is last.
+func (b containerBuild) HashedBuildArgs() []string {
+	res := make([]string, 0, len(b.BuildArgs))
+	for i := 0; i < len(b.BuildArgs)-1; i++ {
+		if b.BuildArgs[i] == "--manifest" || b.BuildArgs[i] == "--tag" {
+			i++
+			continue
+		}
+		res = append(res, b.BuildArgs[i])
+	}
+	return res
+}
+
+// CommandArgs returns the arguments for the build command, adding the label with the input hash.
+// If parentDigest is set, the container is built on top of the parent image with that digest, which is recorded in a label, rather than on its "latest" tag.
+// The Containerfile is read from stdin.
+func (b containerBuild) CommandArgs(inputHash string, parentDigest string) []string {
+	args := slices.Clone(b.BuildArgs)
+	labels := []string{"--label", inputHashLabel + "=" + inputHash}
+
+	if b.ParentImage != "" && parentDigest != "" {
+		for i := 1; i < len(args); i++ {
+			if args[i-1] == "--build-arg" && strings.HasPrefix(args[i], "BASE_IMAGE=") {
+				args[i] = "BASE_IMAGE=" + b.ParentImage + "@" + parentDigest
+			}
+		}
+		labels = append(labels, "--label", parentDigestLabel+"="+parentDigest)
+	}
+
+	// Add the labels before the build context, which must be last
+	return slices.Insert(args, len(args)-1, labels...)
+}
 
+// prepareContainerBuild determines the architectures, build args, and effective Containerfile used to build a container.
+// If sourceMarkers is true, the Containerfile includes comments with the source of each section.
+// If the container must not be built, the returned object has SkipReason set and no build args.
+func prepareContainerBuild(flags *buildFlags, containerName string, config *ConfigFile, sourceMarkers bool) (*containerBuild, error) {
 	containerConfig, ok := config.containersMap[containerName]
 	if !ok {
-		return fmt.Errorf("container not found in configuration: %s", containerName)
+		return nil, fmt.Errorf("container not found in configuration: %s", containerName)
 	}
 
-	// Build the container
-	// Creates a manifest with a temporary tag
-	manifestNameTag := flags.buildImageNameTag(containerConfig.ImageName, time.Now().Format("20060102150405"))
+	// Determine the architectures to build for, and whether the container should be built at all
+	archs, skipReason, err := config.ContainerBuildArchs(containerName, flags.DefaultBaseImage, flags.Archs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine architectures to build: %w", err)
+	}
+	build := &containerBuild{
+		Config:     containerConfig,
+		Archs:      archs,
+		SkipReason: skipReason,
+	}
+	if skipReason != "" {
+		return build, nil
+	}
 
-	fmt.Fprintf(os.Stderr, "Building image: %s\n", manifestNameTag)
+	// Creates a manifest with a temporary tag
+	build.ManifestNameTag = flags.buildImageNameTag(containerConfig.ImageName, time.Now().Format("20060102150405"))
+	if parent := config.ParentContainer(containerName); parent != "" {
+		build.ParentImage = flags.buildImageName(config.containersMap[parent].ImageName)
+	}
 
 	// Get CLI flags
-	buildArgs, err := getBuildArgs(flags, containerConfig, config, manifestNameTag)
+	build.BuildArgs, err = getBuildArgs(flags, containerConfig, config, archs, build.ManifestNameTag)
 	if err != nil {
-		return fmt.Errorf("failed to get build args: %w", err)
+		return nil, fmt.Errorf("failed to get build args: %w", err)
 	}
 
 	// Build the effective Containerfile, adding all apps
@@ -141,32 +292,148 @@ func ProcessContainer(flags *buildFlags, containerName string, config *ConfigFil
 	for i, app := range containerConfig.Apps {
 		appObj, ok := config.appsMap[app]
 		if !ok {
-			return fmt.Errorf("container references app '%s', which is not defined in config", app)
+			return nil, fmt.Errorf("container references app '%s', which is not defined in config", app)
 		}
 		apps[i] = appObj
 	}
 	containerfile := Containerfile{
-		WorkDir:   flags.WorkDir,
-		Container: containerName,
-		Apps:      apps,
+		WorkDir:       flags.WorkDir,
+		Container:     containerConfig,
+		Apps:          apps,
+		SourceMarkers: sourceMarkers,
+	}
+	r, err := containerfile.BuildContainerfile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Containerfile: %w", err)
+	}
+	build.Containerfile, err = io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Containerfile: %w", err)
+	}
+
+	return build, nil
+}
+
+// ProcessContainer builds a container and optionally pushes it.
+// State shared with the builds of other containers in the same run is kept in state.
+// All console output is written to log.
+func ProcessContainer(ctx context.Context, flags *buildFlags, containerName string, config *ConfigFile, state *buildState, log io.Writer) (*buildResult, error) {
+	result := &buildResult{
+		Name: containerName,
+	}
+
+	// When building from a lockfile, use the inputs recorded in it
+	// Containers that are skipped are not in the lockfile, so they are left to prepareContainerBuild
+	var locked *buildLockContainer
+	if state.fromLock != nil {
+		_, skipReason, err := config.ContainerBuildArchs(containerName, flags.DefaultBaseImage, flags.Archs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine architectures to build: %w", err)
+		}
+		if skipReason == "" {
+			locked, config, err = state.fromLock.Apply(config, containerName, flags.DefaultBaseImage)
+			if err != nil {
+				return nil, fmt.Errorf("failed to apply lockfile: %w", err)
+			}
+		}
+	}
+
+	build, err := prepareContainerBuild(flags, containerName, config, false)
+	if err != nil {
+		return nil, err
+	}
+	containerConfig := build.Config
+	fmt.Fprintf(log, "Building container '%s': %s\n", containerName, containerConfig.Dir())
+	result.ImageName = flags.buildImageName(containerConfig.ImageName)
+	if build.SkipReason != "" {
+		fmt.Fprintf(log, "Skipping container '%s': %s\n", containerName, build.SkipReason)
+		result.Skipped = true
+		result.SkipReason = build.SkipReason
+		return result, nil
+	}
+
+	if locked != nil {
+		err = locked.Verify(build)
+		if err != nil {
+			return nil, fmt.Errorf("cannot build container '%s' from the lockfile: %w", containerName, err)
+		}
+		if locked.Parent != nil {
+			if locked.Parent.Digest == "" {
+				fmt.Fprintf(log, "Lockfile doesn't have the digest of the parent image, so its 'latest' tag is used\n")
+			}
+			build.ParentImage = locked.Parent.Image
+			build.ParentDigest = locked.Parent.Digest
+		}
+		fmt.Fprintf(log, "Using the inputs in the lockfile, recorded at %s\n", locked.BuiltAt.Format(time.RFC3339))
+	}
+
+	archs := build.Archs
+	manifestNameTag := build.ManifestNameTag
+	result.Archs = archs
+
+	// Compute the hash of the inputs, which is stored as a label in the image
+	result.InputHash, err = state.InputHash(ctx, flags, config, containerName, build)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute input hash: %w", err)
 	}
-	stdin, err := containerfile.BuildContainerfile()
+	fmt.Fprintf(log, "Input hash: %s\n", result.InputHash)
+
+	// If the digest of the parent image is known, build on top of exactly that image
+	parentDigest := build.ParentDigest
+	if parentDigest == "" && build.ParentImage != "" {
+		parentDigest, _ = state.Digest(config.ParentContainer(containerName))
+	}
+
+	// If an image with the same inputs already exists in the registry, re-use it
+	if flags.SkipUnchanged {
+		reused, err := reuseUnchangedImage(ctx, flags, containerConfig, build, result, log)
+		if err != nil {
+			return nil, err
+		}
+		if reused {
+			err = state.SetDigest(containerName, result.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to save digest: %w", err)
+			}
+			err = state.RecordLock(containerName, newBuildLockContainer(flags, config, containerName, build, result, parentDigest))
+			if err != nil {
+				return nil, fmt.Errorf("failed to record container in lockfile: %w", err)
+			}
+			return result, nil
+		}
+	}
+
+	// Make sure the base image is available for all archs, since builds otherwise fail with unclear errors
+	err = verifyBaseImagePlatforms(ctx, flags, config, containerConfig, archs, state, log)
 	if err != nil {
-		return fmt.Errorf("failed to build Containerfile: %w", err)
+		return nil, err
+	}
+
+	fmt.Fprintf(log, "Building image: %s (%s)\n", manifestNameTag, strings.Join(archs, ", "))
+
+	if parentDigest != "" {
+		fmt.Fprintf(log, "Building on top of parent image: %s@%s\n", build.ParentImage, parentDigest)
 	}
 
+	buildArgs := build.CommandArgs(result.InputHash, parentDigest)
+	stdin := bytes.NewReader(build.Containerfile)
+
 	err = runProcess(runProcessOpts{
-		Name:  flags.Platform,
-		Args:  buildArgs,
-		Stdin: stdin,
+		Context: ctx,
+		Name:    flags.Platform,
+		Args:    buildArgs,
+		Stdin:   stdin,
+		Console: log,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to build container: %w", err)
+		return nil, fmt.Errorf("failed to build container: %w", err)
 	}
 
 	// Tag as latest
 	err = runProcess(runProcessOpts{
-		Name: flags.Platform,
+		Context: ctx,
+		Name:    flags.Platform,
+		Console: log,
 		Args: []string{
 			"tag",
 			manifestNameTag,
@@ -174,22 +441,22 @@ func ProcessContainer(flags *buildFlags, containerName string, config *ConfigFil
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to tag manifest '%s': %w", manifestNameTag, err)
+		return nil, fmt.Errorf("failed to tag manifest '%s': %w", manifestNameTag, err)
 	}
 
-	result.ImageName = flags.buildImageName(containerConfig.ImageName)
-
 	// Push if desired
 	if flags.Push {
 		for _, tag := range flags.Tags {
 			push := flags.buildImageNameTag(containerConfig.ImageName, tag)
 
-			fmt.Fprintf(os.Stderr, "Pushing: %s\n", push)
+			fmt.Fprintf(log, "Pushing: %s\n", push)
 
 			// With Docker, we need to tag AND push
 			if flags.IsPodman() {
 				err = runProcess(runProcessOpts{
-					Name: "podman",
+					Context: ctx,
+					Name:    "podman",
+					Console: log,
 					Args: []string{
 						"manifest", "push",
 						"--all",
@@ -198,11 +465,13 @@ func ProcessContainer(flags *buildFlags, containerName string, config *ConfigFil
 					},
 				})
 				if err != nil {
-					return fmt.Errorf("failed to push manifest: %w", err)
+					return nil, fmt.Errorf("failed to push manifest: %w", err)
 				}
 			} else {
 				err = runProcess(runProcessOpts{
-					Name: "docker",
+					Context: ctx,
+					Name:    "docker",
+					Console: log,
 					Args: []string{
 						"tag",
 						manifestNameTag,
@@ -210,18 +479,20 @@ func ProcessContainer(flags *buildFlags, containerName string, config *ConfigFil
 					},
 				})
 				if err != nil {
-					return fmt.Errorf("failed to tag manifest: %w", err)
+					return nil, fmt.Errorf("failed to tag manifest: %w", err)
 				}
 
 				err = runProcess(runProcessOpts{
-					Name: "docker",
+					Context: ctx,
+					Name:    "docker",
+					Console: log,
 					Args: []string{
 						"push",
 						push,
 					},
 				})
 				if err != nil {
-					return fmt.Errorf("failed to push manifest: %w", err)
+					return nil, fmt.Errorf("failed to push manifest: %w", err)
 				}
 			}
 
@@ -232,23 +503,151 @@ func ProcessContainer(flags *buildFlags, containerName string, config *ConfigFil
 		// Get the digest of the image
 		// This works reliably only after the image has been pushed
 		rc := regclient.New(regclient.WithDockerCreds())
-		result.Digest, err = getImageDigest(context.TODO(), rc, flags.buildImageNameTag(containerConfig.ImageName, "latest"))
+		result.Digest, err = getImageDigest(ctx, rc, flags.buildImageNameTag(containerConfig.ImageName, "latest"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get digest for image: %w", err)
+		}
+		err = state.SetDigest(containerName, result.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save digest: %w", err)
+		}
+	} else {
+		// The digest of the image is not known until it's pushed, so containers built on top of it use the local image
+		// This removes any digest of an older image, such as one loaded from the state file
+		err = state.SetDigest(containerName, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to save digest: %w", err)
+		}
+
+		// Containers built on top of this one include the ID of the local image in their input hash
+		id, err := localImageID(ctx, flags, manifestNameTag)
 		if err != nil {
-			return fmt.Errorf("failed to get digest for image: %w", err)
+			return nil, fmt.Errorf("failed to get ID of image '%s': %w", manifestNameTag, err)
 		}
+		state.SetLocalImageID(containerName, id)
+	}
+
+	err = state.RecordLock(containerName, newBuildLockContainer(flags, config, containerName, build, result, parentDigest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record container in lockfile: %w", err)
 	}
 
-	// Print the result
-	fmt.Println(result)
+	return result, nil
+}
+
+// reuseUnchangedImage checks if the image in the registry was built with the same inputs, and if so, updates result to re-use it.
+// When pushing, the image is tagged with all tags, without being rebuilt.
+// Returns true if the image was re-used.
+func reuseUnchangedImage(ctx context.Context, flags *buildFlags, containerConfig *ContainerConfig, build *containerBuild, result *buildResult, log io.Writer) (bool, error) {
+	latest := flags.buildImageNameTag(containerConfig.ImageName, "latest")
+	rc := regclient.New(regclient.WithDockerCreds())
+
+	// The image for each arch must have been built with the same inputs
+	for _, arch := range build.Archs {
+		labels, err := getImageLabels(ctx, rc, latest, "linux/"+arch)
+		if err != nil {
+			fmt.Fprintf(log, "Could not get the input hash of image '%s' for arch '%s', building it: %v\n", latest, arch, err)
+			return false, nil
+		}
+		if labels[inputHashLabel] != result.InputHash {
+			fmt.Fprintf(log, "Input hash of image '%s' for arch '%s' is '%s', building it\n", latest, arch, labels[inputHashLabel])
+			return false, nil
+		}
+	}
+
+	fmt.Fprintf(log, "Image '%s' has the same input hash, skipping build\n", latest)
+	var err error
+	result.Digest, err = getImageDigest(ctx, rc, latest)
+	if err != nil {
+		return false, fmt.Errorf("failed to get digest for image: %w", err)
+	}
+
+	if flags.Push {
+		for _, tag := range flags.Tags {
+			push := flags.buildImageNameTag(containerConfig.ImageName, tag)
+			if tag != "latest" {
+				fmt.Fprintf(log, "Tagging: %s\n", push)
+				err = copyImageTag(ctx, rc, latest, push)
+				if err != nil {
+					return false, fmt.Errorf("failed to tag image '%s': %w", push, err)
+				}
+			}
+			result.Tags = append(result.Tags, tag)
+			result.Pushed = append(result.Pushed, push)
+		}
+	}
+
+	result.Skipped = true
+	result.Unchanged = true
+	result.SkipReason = "an image with the same input hash already exists"
+	return true, nil
+}
+
+// localImageID returns a value that identifies an image built locally.
+// With Podman, images are built in a manifest list, which doesn't have an ID, so this is the hash of the manifest list, which contains the digests of the images for each arch.
+func localImageID(ctx context.Context, flags *buildFlags, image string) (string, error) {
+	args := []string{"image", "inspect", "--format", "{{.Id}}", image}
+	if flags.IsPodman() {
+		args = []string{"manifest", "inspect", image}
+	}
+
+	out := &bytes.Buffer{}
+	err := runProcess(runProcessOpts{
+		Context:   ctx,
+		Name:      flags.Platform,
+		Args:      args,
+		Stdout:    out,
+		NoConsole: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if flags.IsPodman() {
+		h := sha256.Sum256(out.Bytes())
+		return "sha256:" + hex.EncodeToString(h[:]), nil
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// verifyBaseImagePlatforms checks that the base image of the container, if it's one of the base images in the config, contains the platforms for all archs.
+// Containers built on top of other containers are not checked, since they're built for a subset of the archs of their parent.
+func verifyBaseImagePlatforms(ctx context.Context, flags *buildFlags, config *ConfigFile, containerConfig *ContainerConfig, archs []string, state *buildState, log io.Writer) error {
+	baseImageName := containerConfig.BaseImage
+	if baseImageName == "default" {
+		baseImageName = flags.DefaultBaseImage
+	}
+	baseImage, ok := config.BaseImages[baseImageName]
+	if !ok {
+		return nil
+	}
+
+	image := baseImage.Image + "@" + baseImage.Digest
+	rc := regclient.New(regclient.WithDockerCreds())
+	available, err := state.ImagePlatforms(ctx, rc, image)
+	if err != nil {
+		return fmt.Errorf("failed to get platforms of base image '%s': %w", baseImageName, err)
+	}
+	err = checkImagePlatforms(available, image, archs)
+	if err != nil {
+		return fmt.Errorf("base image '%s' cannot be used to build for architectures %s: %w", baseImageName, strings.Join(archs, ", "), err)
+	}
 
+	fmt.Fprintf(log, "Base image '%s' contains all platforms to build for\n", baseImageName)
 	return nil
 }
 
 type buildResult struct {
-	Digest    string   `json:"digest,omitempty"`
-	ImageName string   `json:"imageName,omitempty"`
-	Tags      []string `json:"tags,omitempty"`
-	Pushed    []string `json:"pushed,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Digest     string   `json:"digest,omitempty"`
+	ImageName  string   `json:"imageName,omitempty"`
+	Archs      []string `json:"archs,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Pushed     []string `json:"pushed,omitempty"`
+	Skipped    bool     `json:"skipped,omitempty"`
+	SkipReason string   `json:"skipReason,omitempty"`
+	InputHash  string   `json:"inputHash,omitempty"`
+	Unchanged  bool     `json:"unchanged,omitempty"`
 }
 
 func (r buildResult) String() string {
@@ -256,7 +655,7 @@ func (r buildResult) String() string {
 	return string(j)
 }
 
-func getBuildArgs(flags *buildFlags, containerConfig *ContainerConfig, config *ConfigFile, manifestNameTag string) ([]string, error) {
+func getBuildArgs(flags *buildFlags, containerConfig *ContainerConfig, config *ConfigFile, archs []string, manifestNameTag string) ([]string, error) {
 	// Base image
 	baseImageName := containerConfig.BaseImage
 	if baseImageName == "default" {
@@ -276,8 +675,8 @@ func getBuildArgs(flags *buildFlags, containerConfig *ContainerConfig, config *C
 	}
 
 	// List of platforms
-	platforms := make([]string, len(flags.Archs))
-	for i, a := range flags.Archs {
+	platforms := make([]string, len(archs))
+	for i, a := range archs {
 		platforms[i] = "linux/" + a
 	}
 
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestBuildConfig returns a config with the "base" container, and "server" built on top of it.
+func newTestBuildConfig(t *testing.T) (*ConfigFile, *buildFlags) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml":                      testBaseImagesConfig + "containers:\n  - base\n  - server\n",
+		"containers/base/container.yaml":   "imageName: base\nbaseImage: default\n",
+		"containers/base/Containerfile":    "FROM ${BASE_IMAGE}\nRUN echo base",
+		"containers/server/container.yaml": "imageName: server\nbaseImage: base\n",
+		"containers/server/Containerfile":  "FROM ${BASE_IMAGE}\nRUN echo server",
+	})
+
+	config, err := LoadConfigFile(dir, "config.yaml", "")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	flags := &buildFlags{
+		WorkDir:          dir,
+		DefaultBaseImage: "test-base",
+		Platform:         "podman",
+		Repository:       "example.com/repo",
+		Archs:            []string{"amd64"},
+	}
+	return config, flags
+}
+
+// buildArgValues returns the value of the build arg with the given name, and the values of the labels.
+func buildArgValues(args []string, name string) (string, map[string]string) {
+	var value string
+	labels := map[string]string{}
+	for i := 1; i < len(args); i++ {
+		switch args[i-1] {
+		case "--build-arg":
+			v, ok := strings.CutPrefix(args[i], name+"=")
+			if ok {
+				value = v
+			}
+		case "--label":
+			k, v, _ := strings.Cut(args[i], "=")
+			labels[k] = v
+		}
+	}
+	return value, labels
+}
+
+func TestContainerBuildCommandArgs(t *testing.T) {
+	const parentDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	config, flags := newTestBuildConfig(t)
+
+	tests := []struct {
+		name         string
+		container    string
+		parentDigest string
+		baseImage    string
+		// Expected value of the parent digest label, or empty if the label must not be set
+		parentLabel string
+	}{
+		{
+			name:         "parent digest",
+			container:    "server",
+			parentDigest: parentDigest,
+			baseImage:    "example.com/repo/base@" + parentDigest,
+			parentLabel:  parentDigest,
+		},
+		{
+			// Without a digest, the container is built on the "latest" tag of the parent
+			name:      "missing parent digest",
+			container: "server",
+			baseImage: "example.com/repo/base:latest",
+		},
+		{
+			// Containers built on a base image in the config always use its digest
+			name:         "not built on a container",
+			container:    "base",
+			parentDigest: parentDigest,
+			baseImage:    "example.com/test/base@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build, err := prepareContainerBuild(flags, tt.container, config, false)
+			if err != nil {
+				t.Fatalf("failed to prepare build: %v", err)
+			}
+			originalArgs := slices.Clone(build.BuildArgs)
+
+			args := build.CommandArgs("hash", tt.parentDigest)
+			baseImage, labels := buildArgValues(args, "BASE_IMAGE")
+			if baseImage != tt.baseImage {
+				t.Errorf("got BASE_IMAGE '%s', expected '%s'", baseImage, tt.baseImage)
+			}
+			if labels[inputHashLabel] != "hash" {
+				t.Errorf("wrong input hash label '%s'", labels[inputHashLabel])
+			}
+			if labels[parentDigestLabel] != tt.parentLabel {
+				t.Errorf("got parent digest label '%s', expected '%s'", labels[parentDigestLabel], tt.parentLabel)
+			}
+
+			// The build context is still the last argument, and the args of the build are not modified
+			if args[len(args)-1] != originalArgs[len(originalArgs)-1] {
+				t.Errorf("build context is not the last argument: %v", args)
+			}
+			if !slices.Equal(build.BuildArgs, originalArgs) {
+				t.Errorf("build args were modified: %v", build.BuildArgs)
+			}
+		})
+	}
+}
+
+func TestBuildStateFile(t *testing.T) {
+	const parentDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	config, flags := newTestBuildConfig(t)
+	stateFile := filepath.Join(t.TempDir(), "build-state.json")
+
+	// The first invocation builds the parent, and the state file doesn't exist yet
+	first, err := loadBuildState(stateFile, flags, config)
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	if _, ok := first.Digest("base"); ok {
+		t.Error("found a digest in a new state")
+	}
+	err = first.SetDigest("base", parentDigest)
+	if err != nil {
+		t.Fatalf("failed to set digest: %v", err)
+	}
+
+	// Digests are keyed by the name of the image
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatalf("failed to read state file: %v", err)
+	}
+	var fileState buildStateFile
+	err = json.Unmarshal(data, &fileState)
+	if err != nil {
+		t.Fatalf("failed to parse state file: %v", err)
+	}
+	if fileState.Images["example.com/repo/base"] != parentDigest {
+		t.Errorf("unexpected content of the state file: %s", data)
+	}
+
+	// The second invocation builds the child on top of the parent's digest
+	second, err := loadBuildState(stateFile, flags, config)
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	digest, _ := second.Digest(config.ParentContainer("server"))
+	if digest != parentDigest {
+		t.Fatalf("got parent digest '%s', expected '%s'", digest, parentDigest)
+	}
+	build, err := prepareContainerBuild(flags, "server", config, false)
+	if err != nil {
+		t.Fatalf("failed to prepare build: %v", err)
+	}
+	baseImage, labels := buildArgValues(build.CommandArgs("hash", digest), "BASE_IMAGE")
+	if baseImage != "example.com/repo/base@"+parentDigest || labels[parentDigestLabel] != parentDigest {
+		t.Errorf("child is not built on the parent digest: BASE_IMAGE '%s', labels %v", baseImage, labels)
+	}
+
+	// Digests of images in other repositories are not used
+	otherFlags := *flags
+	otherFlags.Repository = "example.com/other"
+	other, err := loadBuildState(stateFile, &otherFlags, config)
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	if _, ok := other.Digest("base"); ok {
+		t.Error("found the digest of an image in another repository")
+	}
+
+	// Removing the digest updates the file
+	err = second.SetDigest("base", "")
+	if err != nil {
+		t.Fatalf("failed to remove digest: %v", err)
+	}
+	third, err := loadBuildState(stateFile, flags, config)
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	if _, ok := third.Digest("base"); ok {
+		t.Error("removed digest is still in the state file")
+	}
+}
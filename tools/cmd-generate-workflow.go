@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed templates/build-containers.yaml.tmpl
+var buildContainersWorkflowTemplate string
+
+func init() {
+	flags := &generateWorkflowFlags{}
+
+	generateWorkflowCmd := &cobra.Command{
+		Use:   "generate-workflow",
+		Short: "Generates the GitHub Actions workflow that builds the containers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Validate flags
+			err := flags.Validate()
+			if err != nil {
+				return err
+			}
+
+			// Find the work dirs if not specified
+			workDirs := flags.WorkDirs
+			if len(workDirs) == 0 {
+				workDirs, err = FindWorkDirs(flags.Root)
+				if err != nil {
+					return fmt.Errorf("failed to find work dirs: %w", err)
+				}
+			}
+
+			// Render the workflow
+			rendered, err := renderBuildWorkflow(flags.Root, workDirs)
+			if err != nil {
+				return fmt.Errorf("failed to render workflow: %w", err)
+			}
+
+			outPath := filepath.Join(flags.Root, flags.Output)
+
+			// In check mode, compare with the file on disk
+			if flags.Check {
+				existing, err := os.ReadFile(outPath)
+				if err != nil {
+					return fmt.Errorf("failed to read workflow file '%s': %w", outPath, err)
+				}
+				if !bytes.Equal(existing, rendered) {
+					return fmt.Errorf("workflow file '%s' is not up to date: run the generate-workflow command and commit the result", outPath)
+				}
+				fmt.Fprintf(os.Stderr, "Workflow file '%s' is up to date\n", outPath)
+				return nil
+			}
+
+			fmt.Fprintf(os.Stderr, "Writing workflow file: %s\n", outPath)
+			err = os.WriteFile(outPath, rendered, 0o644)
+			if err != nil {
+				return fmt.Errorf("failed to write workflow file: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	generateWorkflowCmd.Flags().StringVar(&flags.Root, "root", ".", "Root of the repository")
+	generateWorkflowCmd.Flags().StringSliceVarP(&flags.WorkDirs, "work-dir", "w", nil, "Working directories, relative to the root; if empty, all folders in the root that contain a config.yaml file")
+	generateWorkflowCmd.Flags().StringVarP(&flags.Output, "output", "o", ".github/workflows/build-containers.yaml", "Path of the workflow file, relative to the root")
+	generateWorkflowCmd.Flags().BoolVar(&flags.Check, "check", false, "Do not write the workflow file, but return an error if it's not up to date")
+
+	rootCmd.AddCommand(generateWorkflowCmd)
+}
+
+type generateWorkflowFlags struct {
+	Root     string
+	WorkDirs []string
+	Output   string
+	Check    bool
+}
+
+func (f *generateWorkflowFlags) Validate() error {
+	if f.Root == "" {
+		return errors.New("flag --root must not be empty")
+	}
+	if f.Output == "" {
+		return errors.New("flag --output must not be empty")
+	}
+	return nil
+}
+
+type buildWorkflowData struct {
+	Matrix     []buildWorkflowMatrixEntry
+	Containers []buildWorkflowContainer
+}
+
+type buildWorkflowMatrixEntry struct {
+	WorkDir   string
+	BaseImage string
+}
+
+type buildWorkflowContainer struct {
+	Name string
+	// Work dirs that contain the container; empty if the container is in all work dirs
+	WorkDirs []string
+}
+
+func renderBuildWorkflow(root string, workDirs []string) ([]byte, error) {
+	data := buildWorkflowData{}
+
+	// Load all config files, without overrides which are for local use only
+	containerWorkDirs := map[string][]string{}
+	for _, wd := range workDirs {
+		config, err := LoadConfigFile(filepath.Join(root, wd), "config.yaml", "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file for work dir '%s': %w", wd, err)
+		}
+
+		// Add each base image to the matrix
+		baseImages := make([]string, 0, len(config.BaseImages))
+		for name := range config.BaseImages {
+			baseImages = append(baseImages, name)
+		}
+		slices.Sort(baseImages)
+		for _, name := range baseImages {
+			data.Matrix = append(data.Matrix, buildWorkflowMatrixEntry{
+				WorkDir:   filepath.ToSlash(wd),
+				BaseImage: name,
+			})
+		}
+
+		// Add containers in dependency order
+		containers, err := config.AllContainers()
+		if err != nil {
+			return nil, fmt.Errorf("failed to sort containers for work dir '%s': %w", wd, err)
+		}
+		for _, name := range containers {
+			if _, ok := containerWorkDirs[name]; !ok {
+				data.Containers = append(data.Containers, buildWorkflowContainer{Name: name})
+			}
+			containerWorkDirs[name] = append(containerWorkDirs[name], filepath.ToSlash(wd))
+		}
+	}
+
+	// Restrict containers that are not in every work dir
+	for i, c := range data.Containers {
+		if len(containerWorkDirs[c.Name]) < len(workDirs) {
+			data.Containers[i].WorkDirs = containerWorkDirs[c.Name]
+		}
+	}
+
+	tpl, err := template.New("workflow").
+		Delims("[[", "]]").
+		Funcs(template.FuncMap{
+			"join": strings.Join,
+			"workDirCondition": func(workDirs []string) string {
+				conds := make([]string, len(workDirs))
+				for i, wd := range workDirs {
+					conds[i] = "matrix.workDir == '" + wd + "'"
+				}
+				if len(conds) == 1 {
+					return conds[0]
+				}
+				return "(" + strings.Join(conds, " || ") + ")"
+			},
+		}).
+		Parse(buildContainersWorkflowTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	out := &bytes.Buffer{}
+	err = tpl.Execute(out, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderBuildWorkflow(t *testing.T) {
+	root := t.TempDir()
+	workDir := func(wd string, containers ...string) {
+		files := map[string]string{
+			"config.yaml": "baseImages:\n" +
+				"  alma:\n    image: example.com/alma\n    tag: \"10\"\n" +
+				"  centos:\n    image: example.com/centos\n    tag: \"10\"\n" +
+				"containers:\n  - " + strings.Join(containers, "\n  - ") + "\n",
+		}
+		for _, c := range containers {
+			baseImage := "default"
+			if c != "base" {
+				baseImage = "base"
+			}
+			files["containers/"+c+"/container.yaml"] = "imageName: " + c + "\nbaseImage: " + baseImage + "\n"
+			files["containers/"+c+"/Containerfile"] = "FROM ${BASE_IMAGE}\n"
+		}
+		writeTestFiles(t, filepath.Join(root, wd), files)
+	}
+	// "base" is in all work dirs, "server" in one only, and "desktop" in two
+	workDir("alpha", "server", "base")
+	workDir("beta", "base", "desktop")
+	workDir("gamma", "desktop", "base")
+
+	res, err := renderBuildWorkflow(root, []string{"alpha", "beta", "gamma"})
+	if err != nil {
+		t.Fatalf("failed to render workflow: %v", err)
+	}
+
+	checkGolden(t, filepath.Join("testdata", "generate-workflow", "build-containers.golden.yaml"), res)
+
+	// Each container is attested with its own digest, and only in the work dirs that contain it
+	for _, s := range []string{
+		"if: ${{ !cancelled() && steps.build-and-push.outputs.Digest-base != '' }}",
+		"if: ${{ matrix.workDir == 'alpha' && !cancelled() && steps.build-and-push.outputs.Digest-server != '' }}",
+		"if: ${{ (matrix.workDir == 'beta' || matrix.workDir == 'gamma') && !cancelled() && steps.build-and-push.outputs.Digest-desktop != '' }}",
+		"subject-digest: ${{ steps.build-and-push.outputs.Digest-server }}",
+	} {
+		if !strings.Contains(string(res), s) {
+			t.Errorf("rendered workflow does not contain %q", s)
+		}
+	}
+}
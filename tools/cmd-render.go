@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	flags := &renderFlags{}
+
+	renderCmd := &cobra.Command{
+		Use:   "render <container>",
+		Short: "Print the effective Containerfile and build command for a container",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Validate flags
+			err := flags.Validate()
+			if err != nil {
+				return err
+			}
+			containerName := args[0]
+
+			// Load the config file
+			config, err := LoadConfigFile(flags.WorkDir, "config.yaml", "config.override.yaml")
+			if err != nil {
+				return fmt.Errorf("failed to load config file: %w", err)
+			}
+
+			// Prepare the build, same as the build command
+			build, err := prepareContainerBuild(&flags.buildFlags, containerName, config, flags.SourceMarkers)
+			if err != nil {
+				return fmt.Errorf("failed to prepare build for container '%s': %w", containerName, err)
+			}
+			if build.SkipReason != "" {
+				return fmt.Errorf("container '%s' is not built: %s", containerName, build.SkipReason)
+			}
+
+			// Compute the input hash the same way as the build command, without the source markers
+			// When resolving the parent image, its digest in the repository is used, like when building with --push or --skip-unchanged; otherwise, like in local builds, the input hash of the parent is used
+			state := newBuildState()
+			state.resolveParentDigest = flags.ResolveParent
+			inputHash, err := state.InputHash(cmd.Context(), &flags.buildFlags, config, containerName, nil)
+			if err != nil {
+				return fmt.Errorf("failed to compute input hash: %w", err)
+			}
+			parentDigest, _ := state.Digest(config.ParentContainer(containerName))
+			if flags.ResolveParent && build.ParentImage != "" {
+				if parentDigest == "" {
+					fmt.Fprintf(os.Stderr, "Parent image '%s' not found in the repository, using the input hash of its container\n", build.ParentImage)
+				} else {
+					fmt.Fprintf(os.Stderr, "Building on top of parent image: %s@%s\n", build.ParentImage, parentDigest)
+				}
+			}
+			args = build.CommandArgs(inputHash, parentDigest)
+
+			// Print to the console
+			if flags.OutputDir == "" {
+				fmt.Print(string(build.Containerfile))
+				fmt.Println("# ----- Build command (the Containerfile is read from stdin) -----")
+				fmt.Println(formatShellCommand(flags.Platform, quoteShellArgs(args)))
+				return nil
+			}
+
+			// Write to the output directory
+			err = os.MkdirAll(flags.OutputDir, 0o755)
+			if err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
+
+			containerfilePath := filepath.Join(flags.OutputDir, "Containerfile")
+			fmt.Fprintf(os.Stderr, "Writing Containerfile: %s\n", containerfilePath)
+			err = os.WriteFile(containerfilePath, build.Containerfile, 0o644)
+			if err != nil {
+				return fmt.Errorf("failed to write Containerfile: %w", err)
+			}
+
+			// In the script, read the Containerfile from the same directory
+			quoted := quoteShellArgs(args)
+			for i := range len(args) - 1 {
+				if args[i] == "--file" && args[i+1] == "-" {
+					quoted[i+1] = `"$(dirname "$0")/Containerfile"`
+				}
+			}
+			script := "#!/bin/sh\n" +
+				"# Generated by the \"render\" command of the tools app\n" +
+				"set -e\n\n" +
+				formatShellCommand(flags.Platform, quoted) + "\n"
+
+			scriptPath := filepath.Join(flags.OutputDir, "build.sh")
+			fmt.Fprintf(os.Stderr, "Writing build script: %s\n", scriptPath)
+			err = os.WriteFile(scriptPath, []byte(script), 0o755)
+			if err != nil {
+				return fmt.Errorf("failed to write build script: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	renderCmd.Flags().StringVarP(&flags.WorkDir, "work-dir", "w", ".", "Working directory, containing the config files, the apps, and containers")
+	renderCmd.Flags().StringVarP(&flags.DefaultBaseImage, "default-base-image", "b", "", "Name of the default base image to use, from the versions file")
+	renderCmd.Flags().StringVar(&flags.Platform, "platform", "podman", "Container platform to use: 'podman' or 'docker'")
+	renderCmd.Flags().StringVarP(&flags.Repository, "repository", "r", "localhost/bootc", "Base repository for tagging images")
+	renderCmd.Flags().StringSliceVarP(&flags.Archs, "arch", "a", []string{"amd64"}, "Architecture(s) for building the image; containers are built only for the archs they support")
+	renderCmd.Flags().BoolVar(&flags.SourceMarkers, "source-markers", true, "Add a comment with the source file before each section of the Containerfile")
+	renderCmd.Flags().BoolVar(&flags.ResolveParent, "resolve-parent", false, "For containers built on top of another, get the digest of the parent image from the repository, like the build command does when pushing or skipping unchanged images")
+	renderCmd.Flags().StringVarP(&flags.OutputDir, "output-dir", "o", "", "If set, write the Containerfile and a build.sh script to this directory instead of printing them")
+
+	rootCmd.AddCommand(renderCmd)
+}
+
+type renderFlags struct {
+	buildFlags
+
+	SourceMarkers bool
+	ResolveParent bool
+	OutputDir     string
+}
+
+func (f *renderFlags) Validate() error {
+	if len(f.Archs) == 0 {
+		return errors.New("at least one --arch flag must be specified")
+	}
+	if f.DefaultBaseImage == "" {
+		return errors.New("flag --default-base-image must not be empty")
+	}
+	if f.Repository == "" {
+		return errors.New("flag --repository must not be empty")
+	}
+	if f.WorkDir == "" {
+		return errors.New("flag --work-dir must not be empty")
+	}
+
+	switch f.Platform {
+	case "podman", "docker":
+		// All good
+	default:
+		return errors.New("invalid value for --platform flag, must be 'podman' or 'docker'")
+	}
+
+	return nil
+}
+
+// Characters that don't need to be quoted in a shell
+var shellSafeExp = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// quoteShellArgs quotes the arguments so they can be used in a POSIX shell.
+func quoteShellArgs(args []string) []string {
+	res := make([]string, len(args))
+	for i, a := range args {
+		if shellSafeExp.MatchString(a) {
+			res[i] = a
+		} else {
+			res[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+	}
+	return res
+}
+
+// formatShellCommand formats a command with already-quoted arguments, with each flag on its own line.
+func formatShellCommand(name string, quotedArgs []string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for i, a := range quotedArgs {
+		// Keep values on the same line as their flag
+		if (i > 0 && strings.HasPrefix(a, "--")) || i == len(quotedArgs)-1 {
+			sb.WriteString(" \\\n   ")
+		}
+		sb.WriteString(" " + a)
+	}
+	return sb.String()
+}
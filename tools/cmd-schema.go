@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	flags := &schemaFlags{}
+
+	schemaCmd := &cobra.Command{
+		Use:       "schema [config|container|app]",
+		Short:     "Generate the JSON Schema for config, container, and app files",
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: slices.Sorted(maps.Keys(configSchemaTypes)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Validate flags
+			if len(args) > 0 {
+				flags.Type = args[0]
+			}
+			err := flags.Validate()
+			if err != nil {
+				return err
+			}
+
+			// Print a single schema
+			if flags.OutputDir == "" {
+				s, err := generateConfigSchema(flags.Type)
+				if err != nil {
+					return err
+				}
+				fmt.Println(s)
+				return nil
+			}
+
+			// Write all schemas to the output directory
+			err = os.MkdirAll(flags.OutputDir, 0o755)
+			if err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
+			for _, name := range slices.Sorted(maps.Keys(configSchemaTypes)) {
+				s, err := generateConfigSchema(name)
+				if err != nil {
+					return err
+				}
+				outPath := filepath.Join(flags.OutputDir, name+".schema.json")
+				fmt.Fprintf(os.Stderr, "Writing schema file: %s\n", outPath)
+				err = os.WriteFile(outPath, []byte(s+"\n"), 0o644)
+				if err != nil {
+					return fmt.Errorf("failed to write schema file: %w", err)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	schemaCmd.Flags().StringVarP(&flags.OutputDir, "output-dir", "o", "", "If set, write the schemas for all files to this directory, named '<type>.schema.json'")
+
+	rootCmd.AddCommand(schemaCmd)
+}
+
+type schemaFlags struct {
+	Type      string
+	OutputDir string
+}
+
+func (f *schemaFlags) Validate() error {
+	if f.Type == "" && f.OutputDir == "" {
+		return errors.New("the type of file must be passed as argument, or --output-dir must be set")
+	}
+	if f.Type != "" && f.OutputDir != "" {
+		return errors.New("the type of file cannot be passed as argument together with --output-dir")
+	}
+	return nil
+}
+
+// generateConfigSchema returns the JSON Schema for the type of file with the given name, such as "config".
+func generateConfigSchema(name string) (string, error) {
+	t := configSchemaTypes[name]
+
+	schema, err := schemaForType(reflect.TypeOf(t.value))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate schema for '%s': %w", name, err)
+	}
+
+	// Copy the schema before setting the title, as the one returned by schemaForType is shared
+	s := *schema
+	s.Title = t.title
+	s.Description = "Schema for " + t.title + " files (and their " + strings.TrimSuffix(t.title, ".yaml") + ".override.yaml overrides)"
+	return s.String(), nil
+}
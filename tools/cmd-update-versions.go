@@ -2,14 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types/platform"
 	"github.com/spf13/cobra"
 )
 
@@ -27,145 +33,160 @@ func init() {
 				return err
 			}
 
-			flags.WorkDir, err = filepath.Abs(flags.WorkDir)
-			if err != nil {
-				return fmt.Errorf("failed to get path to versions file: %w", err)
-			}
-
-			// Load the config file
-			config, err := LoadConfigFile(flags.WorkDir, flags.ConfigFileName, "")
-			if err != nil {
-				return fmt.Errorf("failed to load versions file: %w", err)
-			}
-
-			// List of updated fields
-			updated := make([]string, 0)
-
-			// Init the registry client
-			rc := regclient.New(regclient.WithDockerCreds())
-
-			// Check for updates for base images
-			var updatedBaseImages bool
-			for imageId, baseImage := range config.BaseImages {
-				if baseImage.Image == "" {
-					continue
+			// Apply a plan created earlier
+			if flags.Apply != "" {
+				plan, err := loadUpdatePlan(flags.Apply)
+				if err != nil {
+					return fmt.Errorf("failed to load plan: %w", err)
 				}
-
-				// Get the latest digest of the tag
-				image := baseImage.Image + ":" + baseImage.Tag
-				fmt.Fprintf(os.Stderr, "Checking for updates for base image %s (%s)\n  Current digest: %s\n", imageId, image, baseImage.Digest)
-
-				digest, err := getImageDigest(cmd.Context(), rc, image)
+				err = plan.Apply(flags.Root)
 				if err != nil {
-					return fmt.Errorf("failed to get digest for image '%s': %w", image, err)
+					return fmt.Errorf("failed to apply plan: %w", err)
 				}
-				fmt.Fprintf(os.Stderr, "  Latest digest: %s\n", digest)
-
-				if digest != baseImage.Digest {
-					baseImage.Digest = digest
-					config.BaseImages[imageId] = baseImage
-					updatedBaseImages = true
-					updated = append(updated, fmt.Sprintf("Base image %s (%s): %s", imageId, image, digest))
+				printUpdateSummary(plan)
+				err = writeUpdateReport(flags.ReportJSON, plan)
+				if err != nil {
+					return err
 				}
+				if failed := plan.FailedChecks(); failed > 0 {
+					fmt.Fprintf(os.Stderr, "Warning: %d update check(s) failed when the plan was created\n", failed)
+				}
+				return nil
 			}
 
-			// Check for updates for apps
-			for appName, app := range config.appsMap {
-				// Skip apps that don't have an update version command
-				if app == nil || app.Cmds == nil || app.Cmds.UpdateVersion == "" {
-					continue
+			// Find the work dirs if not specified
+			workDirs := flags.WorkDirs
+			if len(workDirs) == 0 {
+				workDirs, err = FindWorkDirs(flags.Root)
+				if err != nil {
+					return fmt.Errorf("failed to find work dirs: %w", err)
 				}
+			}
 
-				fmt.Fprintf(os.Stderr, "Checking for updates for app %s\n  Current version: %s\n", appName, app.Version)
-
-				out := &bytes.Buffer{}
-				err = runShellScript(app.Cmds.UpdateVersion, out, true)
+			// Load the config file of each work dir
+			configs := make([]*ConfigFile, len(workDirs))
+			for i, workDir := range workDirs {
+				workDirs[i] = filepath.Clean(workDir)
+				workDirAbs, err := filepath.Abs(filepath.Join(flags.Root, workDir))
 				if err != nil {
-					return fmt.Errorf("failed to get updated version for app '%s': %w", appName, err)
+					return fmt.Errorf("failed to get path to work dir: %w", err)
 				}
-				version := strings.TrimSpace(out.String())
-				fmt.Fprintf(os.Stderr, "  Latest version: %s\n", version)
-
-				if version == app.Version {
-					// Version hasn't changed, so nothing to do
-					fmt.Fprint(os.Stderr, "  App is already at the latest version\n")
-					continue
-				} else if len(app.IgnoredVersions) > 0 && slices.Contains(app.IgnoredVersions, version) {
-					// Version is ignored
-					fmt.Fprint(os.Stderr, "  Latest version is in the ignore list\n")
-					continue
+				configs[i], err = LoadConfigFile(workDirAbs, flags.ConfigFileName, "")
+				if err != nil {
+					return fmt.Errorf("failed to load config file for work dir '%s': %w", workDir, err)
 				}
+			}
 
-				app.Version = version
-				updated = append(updated, fmt.Sprintf("App %s: %s", appName, version))
-
-				// Fetch the updated checksum if needed
-				if app.Cmds.UpdateChecksums != "" {
-					out.Reset()
-					err = runShellScript(app.Cmds.UpdateChecksums, out, true)
-					if err != nil {
-						return fmt.Errorf("failed to get updated checksum for app '%s': %w", appName, err)
-					}
-					checksum := strings.TrimSpace(out.String())
-
-					app.Checksums = checksum
-					fmt.Fprint(os.Stderr, "  Updated checksum\n")
-				}
+			// Init the registry client
+			rc := regclient.New(regclient.WithDockerCreds())
 
-				// Save the updated app
-				fmt.Fprintf(os.Stderr, "Saving updated app version '%s': %s\n", appName, app.SavePath)
-				err = saveYamlFile(app, app.SavePath)
-				if err != nil {
-					return fmt.Errorf("failed to save updated app configuration file: %w", err)
-				}
+			// Init the sources for the latest versions of apps
+			sources := &versionSources{
+				HTTPClient:   &http.Client{},
+				RegClient:    rc,
+				GitHubAPIURL: flags.GitHubAPIURL,
+				GitHubToken:  os.Getenv("GITHUB_TOKEN"),
+				PyPIURL:      flags.PyPIURL,
 			}
 
-			// Save the updated versions file if there have been changes
-			if len(updated) == 0 {
-				fmt.Fprint(os.Stderr, "No changes detected\n")
-				return nil
+			plan, err := planUpdates(cmd.Context(), flags.Root, workDirs, configs, rc, sources, updateCheckOptions{
+				Jobs:    flags.Jobs,
+				Timeout: flags.Timeout,
+				Retries: flags.Retries,
+				Backoff: 2 * time.Second,
+			})
+			if err != nil {
+				return err
 			}
 
-			// Save the updated config file if base images have been updated
-			if updatedBaseImages && config.SavePath != "" {
-				fmt.Fprintf(os.Stderr, "Saving updated config file: %s\n", config.SavePath)
-				err = saveYamlFile(config, config.SavePath)
+			// In dry-run mode, print the plan without changing any file
+			if flags.DryRun {
+				fmt.Println(plan)
+				err = writeUpdateReport(flags.ReportJSON, plan)
 				if err != nil {
-					return fmt.Errorf("failed to save updated config file: %w", err)
+					return err
 				}
+				return failedChecksError(cmd, plan)
 			}
 
-			// Print list of updates as markdown
-			fmt.Println("## " + flags.WorkDir)
-			for _, u := range updated {
-				fmt.Println("- " + u)
+			// Apply the plan
+			// Only the changed values are edited in the files, to preserve comments and formatting
+			err = plan.Apply(flags.Root)
+			if err != nil {
+				return fmt.Errorf("failed to apply updates: %w", err)
+			}
+			printUpdateSummary(plan)
+			err = writeUpdateReport(flags.ReportJSON, plan)
+			if err != nil {
+				return err
 			}
 
-			return nil
+			// Checks that failed make the command fail, after the successful updates are applied
+			return failedChecksError(cmd, plan)
 		},
 	}
 
 	updateVersionsCmd.Flags().StringVar(&flags.Platform, "platform", "podman", "Container platform to use: 'podman' or 'docker'")
-	updateVersionsCmd.Flags().StringVarP(&flags.WorkDir, "work-dir", "w", ".", "Working directory, containing the config file, the apps, and containers")
+	updateVersionsCmd.Flags().StringVar(&flags.Root, "root", ".", "Root of the repository")
+	updateVersionsCmd.Flags().StringSliceVarP(&flags.WorkDirs, "work-dir", "w", nil, "Working directories, relative to the root; if empty, all folders in the root that contain a config.yaml file")
 	updateVersionsCmd.Flags().StringVarP(&flags.ConfigFileName, "config-file-name", "n", "config.yaml", "Name of the config file in the working directory")
+	updateVersionsCmd.Flags().StringVar(&flags.GitHubAPIURL, "github-api-url", "https://api.github.com", "Base URL of the GitHub API, used by sources of type 'github-release'")
+	updateVersionsCmd.Flags().StringVar(&flags.PyPIURL, "pypi-url", "https://pypi.org", "Base URL of PyPI, used by sources of type 'pypi'")
+	updateVersionsCmd.Flags().IntVarP(&flags.Jobs, "jobs", "j", 4, "Number of update checks to run in parallel")
+	updateVersionsCmd.Flags().DurationVar(&flags.Timeout, "timeout", 5*time.Minute, "Timeout for each attempt of an update check; when it expires, the scripts started by the check are killed")
+	updateVersionsCmd.Flags().IntVar(&flags.Retries, "retries", 2, "Number of times a failed update check is retried, with exponential backoff")
+	updateVersionsCmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "Do not change any file, and print the plan of updates as JSON instead")
+	updateVersionsCmd.Flags().StringVar(&flags.Apply, "apply", "", "Apply the plan in this JSON file, created with --dry-run, instead of checking for updates")
+	updateVersionsCmd.Flags().StringVar(&flags.ReportJSON, "report-json", "", "If set, also write the report of the updates as JSON to this file, in the same format as the plan printed by --dry-run")
 
 	rootCmd.AddCommand(updateVersionsCmd)
 }
 
 type updateVersionsFlags struct {
-	WorkDir        string
+	Root           string
+	WorkDirs       []string
 	Platform       string
 	ConfigFileName string
+	GitHubAPIURL   string
+	PyPIURL        string
+	Jobs           int
+	Timeout        time.Duration
+	Retries        int
+	DryRun         bool
+	Apply          string
+	ReportJSON     string
 }
 
 func (f updateVersionsFlags) Validate() error {
 	// Validate required parameters
-	if f.WorkDir == "" {
-		return errors.New("flag --work-dir must not be empty")
+	if f.Root == "" {
+		return errors.New("flag --root must not be empty")
+	}
+	if slices.Contains(f.WorkDirs, "") {
+		return errors.New("flag --work-dir must not contain empty values")
 	}
 	if f.ConfigFileName == "" {
 		return errors.New("flag --config-file-name must not be empty")
 	}
+	if f.GitHubAPIURL == "" {
+		return errors.New("flag --github-api-url must not be empty")
+	}
+	if f.PyPIURL == "" {
+		return errors.New("flag --pypi-url must not be empty")
+	}
+
+	if f.Jobs < 1 {
+		return errors.New("flag --jobs must be at least 1")
+	}
+	if f.Timeout <= 0 {
+		return errors.New("flag --timeout must be greater than zero")
+	}
+	if f.Retries < 0 {
+		return errors.New("flag --retries must not be negative")
+	}
+	if f.DryRun && f.Apply != "" {
+		return errors.New("flags --dry-run and --apply cannot be used together")
+	}
 
 	switch f.Platform {
 	case "podman", "docker":
@@ -180,3 +201,398 @@ func (f updateVersionsFlags) Validate() error {
 func (f updateVersionsFlags) IsPodman() bool {
 	return f.Platform == "podman"
 }
+
+// planUpdates checks for updates for the base images and apps in the configs of the work dirs, returning the plan of the updates.
+// Base images with the same image and tag, and apps with the same source, are checked once and the result is used for all work dirs, so they're kept in sync.
+// Checks run concurrently; checks that fail are recorded in the plan, without stopping the others.
+func planUpdates(ctx context.Context, root string, workDirs []string, configs []*ConfigFile, rc *regclient.RegClient, sources *versionSources, opts updateCheckOptions) (*updatePlan, error) {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for root: %w", err)
+	}
+
+	plan := &updatePlan{
+		Root:       root,
+		WorkDirs:   workDirs,
+		BaseImages: make([]updatePlanBaseImage, 0),
+		Apps:       make([]updatePlanApp, 0),
+	}
+
+	// Index of an entry in the plan, for an item and a work dir
+	type planEntry struct {
+		item    int
+		workDir int
+	}
+
+	// Group of items in the plan that are checked together
+	type checkGroup struct {
+		names    []string
+		workDirs []string
+		entries  []planEntry
+		// For apps, the app in each work dir
+		apps []*App
+	}
+	addToGroup := func(groups map[string]*checkGroup, keys *[]string, key string, name string, workDir string, entry planEntry, app *App) {
+		g, ok := groups[key]
+		if !ok {
+			g = &checkGroup{}
+			groups[key] = g
+			*keys = append(*keys, key)
+		}
+		if !slices.Contains(g.names, name) {
+			g.names = append(g.names, name)
+		}
+		g.workDirs = append(g.workDirs, workDir)
+		g.entries = append(g.entries, entry)
+		g.apps = append(g.apps, app)
+	}
+
+	// Base images, grouped by image and tag
+	baseImageGroups := make(map[string]*checkGroup)
+	baseImageKeys := make([]string, 0)
+	baseImageNames := make(map[string]struct{})
+	// Platform used to read the labels of each image
+	baseImageLabelsPlatforms := make(map[string]string)
+	// Archs that each base image must contain
+	baseImageArchs := make(map[planEntry][]string)
+	for _, config := range configs {
+		for imageId := range config.BaseImages {
+			baseImageNames[imageId] = struct{}{}
+		}
+	}
+	for _, imageId := range slices.Sorted(maps.Keys(baseImageNames)) {
+		item := updatePlanBaseImage{
+			Name:     imageId,
+			WorkDirs: make([]updatePlanBaseImageWorkDir, 0, len(configs)),
+		}
+		for i, config := range configs {
+			baseImage, ok := config.BaseImages[imageId]
+			if !ok || baseImage.Image == "" {
+				continue
+			}
+
+			configFile, err := filepath.Rel(rootAbs, config.SavePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get relative path for config file: %w", err)
+			}
+			image := baseImage.Image + ":" + baseImage.Tag
+			if _, ok := baseImageLabelsPlatforms[image]; !ok {
+				baseImageLabelsPlatforms[image] = "linux/amd64"
+				if len(baseImage.Archs) > 0 {
+					baseImageLabelsPlatforms[image] = "linux/" + baseImage.Archs[0]
+				}
+			}
+			entry := planEntry{item: len(plan.BaseImages), workDir: len(item.WorkDirs)}
+			addToGroup(baseImageGroups, &baseImageKeys, image, imageId, workDirs[i], entry, nil)
+			baseImageArchs[entry] = baseImage.Archs
+			if len(baseImage.Archs) == 0 {
+				baseImageArchs[entry] = allArchs
+			}
+			item.WorkDirs = append(item.WorkDirs, updatePlanBaseImageWorkDir{
+				WorkDir: workDirs[i],
+				File:    configFile,
+				Image:   image,
+				Current: baseImage.Digest,
+			})
+		}
+		if len(item.WorkDirs) > 0 {
+			plan.BaseImages = append(plan.BaseImages, item)
+		}
+	}
+
+	// Apps, grouped by the source of their latest version
+	appGroups := make(map[string]*checkGroup)
+	appKeys := make([]string, 0)
+	appNames := make(map[string]struct{})
+	for _, config := range configs {
+		for appName := range config.appsMap {
+			appNames[appName] = struct{}{}
+		}
+	}
+	for _, appName := range slices.Sorted(maps.Keys(appNames)) {
+		item := updatePlanApp{
+			Name:     appName,
+			WorkDirs: make([]updatePlanAppWorkDir, 0, len(configs)),
+		}
+		for i, config := range configs {
+			app := config.appsMap[appName]
+
+			// Skip apps that don't have a source or an update version command
+			if app == nil || (app.Source == nil && (app.Cmds == nil || app.Cmds.UpdateVersion == "")) {
+				continue
+			}
+
+			appFile, err := filepath.Rel(rootAbs, app.SavePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get relative path for app file: %w", err)
+			}
+			addToGroup(appGroups, &appKeys, appVersionKey(app), appName, workDirs[i], planEntry{item: len(plan.Apps), workDir: len(item.WorkDirs)}, app)
+			item.WorkDirs = append(item.WorkDirs, updatePlanAppWorkDir{
+				WorkDir:          workDirs[i],
+				File:             appFile,
+				Current:          app.Version,
+				CurrentChecksums: app.Checksums,
+			})
+		}
+		if len(item.WorkDirs) > 0 {
+			plan.Apps = append(plan.Apps, item)
+		}
+	}
+
+	// Name of the check for a group
+	// If there are multiple groups with the same names, such as when an app has different sources in each work dir, the work dirs are included too
+	namesCount := make(map[string]int)
+	checkName := func(kind string, g *checkGroup) string {
+		return kind + " " + strings.Join(g.names, ", ")
+	}
+	for _, key := range baseImageKeys {
+		namesCount[checkName("base image", baseImageGroups[key])]++
+	}
+	for _, key := range appKeys {
+		namesCount[checkName("app", appGroups[key])]++
+	}
+	uniqueCheckName := func(kind string, g *checkGroup) string {
+		name := checkName(kind, g)
+		if namesCount[name] > 1 {
+			name += " (" + strings.Join(g.workDirs, ", ") + ")"
+		}
+		return name
+	}
+
+	// Each check sets the entries of its group in the plan
+	checks := make([]updateCheck, 0, len(baseImageKeys)+len(appKeys))
+	setFailed := make([]func(err error), 0, cap(checks))
+
+	// Check for updates for base images
+	for _, image := range baseImageKeys {
+		g := baseImageGroups[image]
+		checks = append(checks, updateCheck{
+			Name: uniqueCheckName("base image", g),
+			Run: func(ctx context.Context, log io.Writer) error {
+				// Get the latest digest of the tag
+				fmt.Fprintf(log, "Checking for updates for base image %s (%s)\n", strings.Join(g.names, ", "), image)
+
+				digest, err := getImageDigest(ctx, rc, image)
+				if err != nil {
+					return fmt.Errorf("failed to get digest for image '%s': %w", image, err)
+				}
+				fmt.Fprintf(log, "  Latest digest: %s\n", digest)
+
+				// Versions of the images are looked up only if there are updates, and once per digest
+				labelsPlatform := baseImageLabelsPlatforms[image]
+				versions := make(map[string]string)
+				imageVersion := func(digest string) string {
+					v, ok := versions[digest]
+					if !ok {
+						v = getImageVersion(ctx, rc, image+"@"+digest, labelsPlatform, log)
+						versions[digest] = v
+					}
+					return v
+				}
+
+				// The new digest must contain the platforms for all archs the config needs
+				var available []platform.Platform
+				for _, e := range g.entries {
+					item := &plan.BaseImages[e.item].WorkDirs[e.workDir]
+					fmt.Fprintf(log, "  Current digest in %s: %s\n", item.WorkDir, item.Current)
+					item.Candidate = digest
+					if digest == item.Current {
+						item.Decision = updateDecisionUnchanged
+						item.Reason = "the tag points to the current digest"
+						continue
+					}
+
+					if available == nil {
+						available, err = getImagePlatforms(ctx, rc, image+"@"+digest)
+						if err != nil {
+							return fmt.Errorf("failed to get platforms of image '%s': %w", image, err)
+						}
+					}
+					err = checkImagePlatforms(available, image+"@"+digest, baseImageArchs[e])
+					if err != nil {
+						fmt.Fprintf(log, "    New digest is rejected: %v\n", err)
+						item.Decision = updateDecisionRejected
+						item.Reason = err.Error()
+						continue
+					}
+
+					item.Decision = updateDecisionUpdate
+					item.Reason = "the tag points to a new digest"
+					item.CandidateVersion = imageVersion(digest)
+					if item.Current != "" {
+						item.CurrentVersion = imageVersion(item.Current)
+					}
+				}
+				return nil
+			},
+		})
+		setFailed = append(setFailed, func(err error) {
+			for _, e := range g.entries {
+				plan.BaseImages[e.item].WorkDirs[e.workDir].Decision = updateDecisionFailed
+				plan.BaseImages[e.item].WorkDirs[e.workDir].Reason = err.Error()
+			}
+		})
+	}
+
+	// Check for updates for apps
+	for _, key := range appKeys {
+		g := appGroups[key]
+		checks = append(checks, updateCheck{
+			Name: uniqueCheckName("app", g),
+			Run: func(ctx context.Context, log io.Writer) error {
+				names := strings.Join(g.names, ", ")
+				fmt.Fprintf(log, "Checking for updates for app %s\n", names)
+
+				// All apps in the group have the same source, so use the first one to get the latest version
+				version, err := sources.AppVersion(ctx, g.apps[0])
+				if err != nil {
+					return fmt.Errorf("failed to get updated version for app '%s': %w", names, err)
+				}
+				fmt.Fprintf(log, "  Latest version: %s\n", version)
+
+				// Checksums are fetched once for apps that get them in the same way
+				checksums := make(map[string]string)
+				for i, e := range g.entries {
+					err = checkAppUpdate(ctx, g.apps[i], version, sources, checksums, &plan.Apps[e.item].WorkDirs[e.workDir], log)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+		setFailed = append(setFailed, func(err error) {
+			for _, e := range g.entries {
+				plan.Apps[e.item].WorkDirs[e.workDir].Decision = updateDecisionFailed
+				plan.Apps[e.item].WorkDirs[e.workDir].Reason = err.Error()
+			}
+		})
+	}
+
+	// Run the checks once all items have been added to the plan
+	errs := runUpdateChecks(ctx, checks, opts)
+	for i, err := range errs {
+		if err != nil {
+			setFailed[i](err)
+		}
+	}
+
+	return plan, nil
+}
+
+// checkAppUpdate decides whether to update the app to the latest version, setting the candidate, the decision, and the checksums in item.
+// Checksums are cached in the checksums map, by the key returned by appChecksumsKey.
+func checkAppUpdate(ctx context.Context, app *App, version string, sources *versionSources, checksums map[string]string, item *updatePlanAppWorkDir, log io.Writer) error {
+	fmt.Fprintf(log, "  Current version in %s: %s\n", item.WorkDir, app.Version)
+
+	// Check that the version is allowed by the update policy
+	var policy App_UpdatePolicy
+	if app.UpdatePolicy != nil {
+		policy = *app.UpdatePolicy
+	}
+	policyErr := policy.CheckUpdate(app.Version, version)
+
+	var appChecksums string
+	switch {
+	case version == app.Version:
+		// Version hasn't changed, so nothing to do
+		fmt.Fprint(log, "    App is already at the latest version\n")
+		item.Decision = updateDecisionUnchanged
+		item.Reason = "the app is already at the latest version"
+	case isIgnoredVersion(app.IgnoredVersions, version):
+		// Version is ignored
+		fmt.Fprint(log, "    Latest version is in the ignore list\n")
+		item.Decision = updateDecisionIgnored
+		item.Reason = "the latest version is in the ignore list"
+	case policyErr != nil:
+		fmt.Fprintf(log, "    Latest version is held back: %v\n", policyErr)
+		item.Decision = updateDecisionHeldBack
+		item.Reason = policyErr.Error()
+	default:
+		// Fetch the updated checksums if needed
+		key := appChecksumsKey(app)
+		cached, ok := checksums[key]
+		switch {
+		case key == "":
+			// App doesn't have checksums
+		case ok:
+			appChecksums = cached
+		case len(app.Artifacts) > 0:
+			var err error
+			appChecksums, err = sources.AppChecksums(ctx, app, version, log)
+			if err != nil {
+				return fmt.Errorf("failed to get updated checksums for app '%s': %w", app.Name, err)
+			}
+		default:
+			out := &bytes.Buffer{}
+			err := runShellScript(ctx, app.Cmds.UpdateChecksums, out, true)
+			if err != nil {
+				return fmt.Errorf("failed to get updated checksum for app '%s': %w", app.Name, err)
+			}
+			appChecksums = strings.TrimSpace(out.String())
+		}
+		if key != "" {
+			checksums[key] = appChecksums
+		}
+		if appChecksums != "" {
+			fmt.Fprint(log, "    Updated checksum\n")
+		}
+
+		item.Decision = updateDecisionUpdate
+		item.Reason = "a new version is available"
+	}
+
+	item.Candidate = version
+	item.Checksums = appChecksums
+	if app.Source != nil && version != app.Version {
+		item.ReleaseURL, item.CompareURL = app.Source.ReleaseLinks(app.Version, version)
+	}
+	return nil
+}
+
+// getImageVersion returns the version of the image, from its org.opencontainers.image.version label.
+// Errors are logged and an empty string is returned, since the version is only informative.
+func getImageVersion(ctx context.Context, rc *regclient.RegClient, image string, platform string, log io.Writer) string {
+	labels, err := getImageLabels(ctx, rc, image, platform)
+	if err != nil {
+		fmt.Fprintf(log, "  Could not get the version of image '%s': %v\n", image, err)
+		return ""
+	}
+	return labels[imageVersionLabel]
+}
+
+// printUpdateSummary prints the summary of the plan as markdown.
+func printUpdateSummary(plan *updatePlan) {
+	if !plan.HasUpdates() {
+		fmt.Fprint(os.Stderr, "No changes detected\n")
+	}
+	summary := plan.Markdown()
+	if summary != "" {
+		fmt.Print(summary)
+	}
+}
+
+// writeUpdateReport writes the plan as JSON to the file, if set.
+func writeUpdateReport(fileName string, plan *updatePlan) error {
+	if fileName == "" {
+		return nil
+	}
+
+	err := os.WriteFile(fileName, []byte(plan.String()+"\n"), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write JSON report: %w", err)
+	}
+	return nil
+}
+
+// failedChecksError returns an error if any update check in the plan failed.
+func failedChecksError(cmd *cobra.Command, plan *updatePlan) error {
+	failed := plan.FailedChecks()
+	if failed == 0 {
+		return nil
+	}
+
+	// The failure is not caused by the usage of the command
+	cmd.SilenceUsage = true
+	return fmt.Errorf("%d update check(s) failed", failed)
+}
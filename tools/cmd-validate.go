@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	flags := &validateFlags{}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config files, containers, and apps for problems",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Validate flags
+			err := flags.Validate()
+			if err != nil {
+				return err
+			}
+
+			// Find the work dirs if not specified
+			workDirs := flags.WorkDirs
+			if len(workDirs) == 0 {
+				workDirs, err = FindWorkDirs(flags.Root)
+				if err != nil {
+					return fmt.Errorf("failed to find work dirs: %w", err)
+				}
+			}
+
+			// Validate all work dirs, collecting all problems
+			problems := make([]validationProblem, 0)
+			for _, workDir := range workDirs {
+				fmt.Fprintf(os.Stderr, "Validating work dir: %s\n", workDir)
+				problems = append(problems, validateWorkDir(filepath.Join(flags.Root, workDir))...)
+			}
+
+			// Print paths relative to the current directory when possible, which are shorter
+			cwd, _ := os.Getwd()
+			for _, p := range problems {
+				rel, err := filepath.Rel(cwd, p.File)
+				if err == nil && filepath.IsAbs(p.File) && filepath.IsLocal(rel) {
+					p.File = rel
+				}
+				fmt.Println(p)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("found %d problem(s)", len(problems))
+			}
+
+			fmt.Fprint(os.Stderr, "No problems found\n")
+			return nil
+		},
+	}
+
+	validateCmd.Flags().StringVar(&flags.Root, "root", ".", "Root of the repository")
+	validateCmd.Flags().StringSliceVarP(&flags.WorkDirs, "work-dir", "w", nil, "Working directories, relative to the root; if empty, all folders in the root that contain a config.yaml file")
+
+	rootCmd.AddCommand(validateCmd)
+}
+
+type validateFlags struct {
+	Root     string
+	WorkDirs []string
+}
+
+func (f *validateFlags) Validate() error {
+	if f.Root == "" {
+		return errors.New("flag --root must not be empty")
+	}
+	return nil
+}
+
+var (
+	// Digests of base images must be SHA-256 digests
+	digestExp = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+	// Lines in checksums, in the format accepted by "sha256sum --check"
+	checksumLineExp = regexp.MustCompile(`^[0-9a-f]{64} [ *]?\S.*$`)
+)
+
+// validateWorkDir checks the configuration in the work dir, returning all problems found.
+// Unlike LoadConfigFile, it also checks the references between base images, containers, and apps, and the values in the apps.
+func validateWorkDir(workDir string) []validationProblem {
+	config, problems := loadConfig(workDir, "config.yaml", "config.override.yaml")
+	if config == nil {
+		return problems
+	}
+	addProblem := func(file string, format string, a ...any) {
+		problems = append(problems, validationProblem{File: file, Message: fmt.Sprintf(format, a...)})
+	}
+	configPath := config.SavePath
+
+	// Base images
+	if len(config.BaseImages) == 0 {
+		addProblem(configPath, "no base images defined")
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.BaseImages)) {
+		baseImage := config.BaseImages[name]
+		if baseImage.Image == "" {
+			addProblem(configPath, "base image '%s' has an empty image", name)
+		}
+		switch {
+		case baseImage.Digest == "":
+			addProblem(configPath, "base image '%s' has an empty digest", name)
+		case !digestExp.MatchString(baseImage.Digest):
+			addProblem(configPath, "base image '%s' has a malformed digest '%s': must be in the format 'sha256:<64 hex characters>'", name, baseImage.Digest)
+		}
+	}
+
+	// Apps
+	appNames := slices.Sorted(maps.Keys(config.appsMap))
+	for _, name := range appNames {
+		problems = append(problems, validateApp(config.appsMap[name])...)
+	}
+
+	// Containers
+	usedApps := make(map[string]bool, len(config.appsMap))
+	for _, name := range config.containerNames {
+		container := config.containersMap[name]
+		containerPath := container.SavePath
+
+		// Base image
+		_, isBaseImage := config.BaseImages[container.BaseImage]
+		_, isContainer := config.containersMap[container.BaseImage]
+		switch {
+		case container.BaseImage == "default", isBaseImage, isContainer:
+			// All good
+		default:
+			addProblem(containerPath, "base image '%s' does not have a match in the list of base images or in other containers", container.BaseImage)
+		}
+		for _, b := range slices.Concat(container.OnlyBaseImages, container.ExcludeBaseImages) {
+			if _, ok := config.BaseImages[b]; !ok {
+				addProblem(containerPath, "base image '%s' in 'onlyBaseImages' or 'excludeBaseImages' is not defined in the config file", b)
+			}
+		}
+
+		// Apps
+		for _, a := range container.Apps {
+			if _, ok := config.appsMap[a]; !ok {
+				addProblem(containerPath, "container references app '%s', which is not defined in config", a)
+				continue
+			}
+			usedApps[a] = true
+		}
+	}
+
+	// Dependency cycles
+	reported := make(map[string]bool)
+	for _, name := range config.containerNames {
+		chain := []string{}
+		for cur := name; cur != ""; cur = config.ParentContainer(cur) {
+			idx := slices.Index(chain, cur)
+			if idx >= 0 {
+				cycle := slices.Clone(chain[idx:])
+				// Report each cycle once, regardless of the container it's found from
+				slices.Sort(cycle)
+				if !reported[strings.Join(cycle, ",")] {
+					reported[strings.Join(cycle, ",")] = true
+					addProblem(configPath, "found a dependency cycle between containers: %s -> %s", strings.Join(chain[idx:], " -> "), cur)
+				}
+				break
+			}
+			chain = append(chain, cur)
+		}
+	}
+
+	// Apps not used by any container
+	for _, a := range appNames {
+		if !usedApps[a] {
+			addProblem(config.appsMap[a].SavePath, "app '%s' is not used by any container", a)
+		}
+	}
+
+	return problems
+}
+
+// validateApp checks the files and values of an app.
+func validateApp(app *App) []validationProblem {
+	problems := make([]validationProblem, 0)
+	addProblem := func(format string, a ...any) {
+		problems = append(problems, validationProblem{File: app.SavePath, Message: fmt.Sprintf(format, a...)})
+	}
+
+	// Read all Containerfiles, which must exist
+	containerfiles := make([]string, 0, len(app.BuilderContainerfiles)+1)
+	for _, cf := range slices.Concat([]string{app.Containerfile}, app.BuilderContainerfiles) {
+		data, err := os.ReadFile(filepath.Join(app.Dir(), cf))
+		if err != nil {
+			addProblem("containerfile '%s' could not be read: %v", cf, err)
+			continue
+		}
+		containerfiles = append(containerfiles, string(data))
+	}
+
+	// The version and checksums are passed as build args, which must be declared
+	upperName := strings.ToUpper(app.Name)
+	for _, arg := range []struct {
+		name  string
+		isSet bool
+	}{
+		{"VERSION_" + upperName, app.Version != ""},
+		{"CHECKSUMS_" + upperName, app.Checksums != ""},
+	} {
+		if !arg.isSet {
+			continue
+		}
+		argExp := regexp.MustCompile(`(?m)^\s*ARG\s+` + regexp.QuoteMeta(arg.name) + `(\s|=|$)`)
+		found := slices.ContainsFunc(containerfiles, argExp.MatchString)
+		if !found {
+			addProblem("containerfiles for app '%s' do not declare 'ARG %s'", app.Name, arg.name)
+		}
+	}
+
+	// Checksums must be in the format used by sha256sum
+	for i, line := range strings.Split(app.Checksums, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !checksumLineExp.MatchString(line) {
+			addProblem("line %d of 'checksums' is not in the format '<sha256>  <filename>': %s", i+1, line)
+		}
+	}
+
+	// Source of the latest version
+	if app.Source != nil {
+		err := app.Source.Validate()
+		if err != nil {
+			addProblem("invalid source: %v", err)
+		}
+		if app.Cmds != nil && app.Cmds.UpdateVersion != "" {
+			addProblem("properties 'source' and 'cmds.updateVersion' cannot be both set")
+		}
+	}
+
+	// Rules for updates
+	if app.UpdatePolicy != nil {
+		err := app.UpdatePolicy.Validate()
+		if err != nil {
+			addProblem("invalid update policy: %v", err)
+		}
+	}
+	err := validateIgnoredVersions(app.IgnoredVersions)
+	if err != nil {
+		addProblem("invalid 'ignoredVersions': %v", err)
+	}
+
+	// Artifacts used to update the checksums
+	for i, a := range app.Artifacts {
+		err := a.Validate()
+		if err != nil {
+			addProblem("invalid artifact %d: %v", i, err)
+		}
+	}
+	if len(app.Artifacts) > 0 && app.Cmds != nil && app.Cmds.UpdateChecksums != "" {
+		addProblem("properties 'artifacts' and 'cmds.updateChecksums' cannot be both set")
+	}
+
+	return problems
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml": testBaseImagesConfig + `containers:
+  - base
+  - server
+  - broken
+  - duplicate
+apps:
+  - tool
+  - other
+  - unused
+`,
+		"containers/base/container.yaml":      "imageName: base\nbaseImage: default\napps:\n  - tool\n",
+		"containers/base/Containerfile":       "FROM ${BASE_IMAGE}",
+		"containers/server/container.yaml":    "imageName: server\nbaseImage: missing\napps:\n  - nope\n",
+		"containers/server/Containerfile":     "FROM ${BASE_IMAGE}",
+		"containers/broken/container.yaml":    "imageName: broken\nbuildContext: ctx\n",
+		"containers/duplicate/container.yaml": "imageName: base\nbaseImage: default\n",
+		"containers/duplicate/Containerfile":  "FROM ${BASE_IMAGE}",
+		"apps/tool/app.yaml":                  "name: tool\nversion: 1.0.0\n",
+		"apps/tool/Containerfile":             "ARG VERSION_TOOL\n",
+		"apps/other/app.yaml":                 "name: tool\n",
+		"apps/unused/app.yaml":                "name: unused\n",
+		"apps/unused/Containerfile":           "",
+	})
+
+	expected := []string{
+		"containers/broken/container.yaml: failed to load container configuration for container 'broken': containerfile '" + dir + "/containers/broken/Containerfile' does not exist",
+		"containers/broken/container.yaml: failed to load container configuration for container 'broken': build context '" + dir + "/containers/broken/ctx' does not exist",
+		"containers/broken/container.yaml: failed to load container configuration for container 'broken': property 'baseImage' is required",
+		"containers/duplicate/container.yaml: image name 'base' is also used by '" + dir + "/containers/base/container.yaml'",
+		"apps/other/app.yaml: app name 'tool' is also used by '" + dir + "/apps/tool/app.yaml'",
+	}
+
+	// Loading the config reports all problems found while loading it
+	_, err := LoadConfigFile(dir, "config.yaml", "")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, e := range expected {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("error does not contain %q:\n%v", e, err)
+		}
+	}
+
+	// Validating the work dir reports the same problems, and then the others
+	expected = append(expected,
+		"containers/server/container.yaml: base image 'missing' does not have a match in the list of base images or in other containers",
+		"containers/server/container.yaml: container references app 'nope', which is not defined in config",
+		"apps/unused/app.yaml: app 'unused' is not used by any container",
+	)
+	problems := validateWorkDir(dir)
+	if len(problems) != len(expected) {
+		t.Errorf("expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+	for _, e := range expected {
+		found := false
+		for _, p := range problems {
+			if strings.HasSuffix(p.String(), e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("problem not found: %s", e)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// configDiff contains the differences between two versions of the config file that affect which containers must be rebuilt.
+type configDiff struct {
+	// If set, all containers must be rebuilt, for the reason included
+	RebuildAllReason string
+	// Names of base images that were added, removed, or changed
+	BaseImages []string
+	// Names of the folders of containers that were added
+	AddedContainers []string
+}
+
+// loadConfigFileAtRevision loads the config file (and the override file, if present) from the given git revision.
+// Only the config file itself is loaded, without the configuration for containers and apps.
+// If the config file doesn't exist at that revision, the second return value is false.
+func loadConfigFileAtRevision(workDir string, rev string, configFileName string, overrideFileName string) (*ConfigFile, bool, error) {
+	config := &ConfigFile{
+		Folders: Config_Folders{
+			Apps:       "apps",
+			Containers: "containers",
+		},
+	}
+
+	for i, name := range []string{configFileName, overrideFileName} {
+		if name == "" {
+			continue
+		}
+
+		data, found, err := gitReadFile(workDir, rev, name)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read file '%s' at revision '%s': %w", name, rev, err)
+		}
+		if !found {
+			// The config file is required, but the override file is optional
+			if i == 0 {
+				return nil, false, nil
+			}
+			continue
+		}
+
+		err = decodeYaml(config, bytes.NewReader(data), rev+":"+name)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to parse file '%s' at revision '%s': %w", name, rev, err)
+		}
+	}
+
+	return config, true, nil
+}
+
+// diffConfigFiles compares the config file at the two revisions.
+func diffConfigFiles(workDir string, baseRef string, headRef string) (*configDiff, error) {
+	oldConfig, found, err := loadConfigFileAtRevision(workDir, baseRef, "config.yaml", "config.override.yaml")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return &configDiff{RebuildAllReason: "config file did not exist at revision " + baseRef}, nil
+	}
+
+	newConfig, found, err := loadConfigFileAtRevision(workDir, headRef, "config.yaml", "config.override.yaml")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return &configDiff{RebuildAllReason: "config file does not exist at revision " + headRef}, nil
+	}
+
+	return compareConfigFiles(oldConfig, newConfig), nil
+}
+
+// compareConfigFiles returns the differences between two versions of the config file.
+func compareConfigFiles(oldConfig *ConfigFile, newConfig *ConfigFile) *configDiff {
+	res := &configDiff{}
+
+	// Changing folders can affect every container
+	if oldConfig.Folders.Apps != newConfig.Folders.Apps || oldConfig.Folders.Containers != newConfig.Folders.Containers {
+		res.RebuildAllReason = "folders changed in config file"
+		return res
+	}
+
+	// Base images that were added, removed, or changed
+	names := slices.Sorted(maps.Keys(oldConfig.BaseImages))
+	for name := range newConfig.BaseImages {
+		if _, ok := oldConfig.BaseImages[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		oldBaseImage, oldOk := oldConfig.BaseImages[name]
+		newBaseImage, newOk := newConfig.BaseImages[name]
+		if oldOk != newOk || !reflect.DeepEqual(oldBaseImage, newBaseImage) {
+			res.BaseImages = append(res.BaseImages, name)
+		}
+	}
+
+	// Containers that were added
+	for _, c := range newConfig.Containers {
+		if !slices.Contains(oldConfig.Containers, c) {
+			res.AddedContainers = append(res.AddedContainers, c)
+		}
+	}
+
+	return res
+}
+
+// ContainersOnBaseImages returns the names of the containers whose chain is rooted at one of the given base images.
+// Containers built on the "default" base image are included if defaultBaseImage is one of the base images, or if defaultBaseImage is empty.
+func (c *ConfigFile) ContainersOnBaseImages(baseImages []string, defaultBaseImage string) []string {
+	if len(baseImages) == 0 {
+		return nil
+	}
+
+	res := make([]string, 0)
+	for _, name := range c.containerNames {
+		baseImage := c.containersMap[c.RootContainer(name)].BaseImage
+		switch {
+		case baseImage == "default" && defaultBaseImage == "":
+			res = append(res, name)
+		case baseImage == "default":
+			if slices.Contains(baseImages, defaultBaseImage) {
+				res = append(res, name)
+			}
+		case slices.Contains(baseImages, baseImage):
+			res = append(res, name)
+		}
+	}
+
+	return res
+}
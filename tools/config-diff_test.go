@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestCompareConfigFiles(t *testing.T) {
+	const (
+		digestA = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+		digestB = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+	)
+	newConfig := func() *ConfigFile {
+		return &ConfigFile{
+			BaseImages: map[string]Config_BaseImages{
+				"alma":   {Image: "example.com/alma", Tag: "10", Digest: digestA},
+				"centos": {Image: "example.com/centos", Tag: "10", Digest: digestA},
+			},
+			Folders:    Config_Folders{Apps: "apps", Containers: "containers"},
+			Containers: []string{"base", "server"},
+			Apps:       []string{"app1", "app2"},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		change   func(c *ConfigFile)
+		expected configDiff
+	}{
+		{
+			name:     "unchanged",
+			change:   func(c *ConfigFile) {},
+			expected: configDiff{},
+		},
+		{
+			name: "base image digest bumped",
+			change: func(c *ConfigFile) {
+				b := c.BaseImages["centos"]
+				b.Digest = digestB
+				c.BaseImages["centos"] = b
+			},
+			expected: configDiff{BaseImages: []string{"centos"}},
+		},
+		{
+			name: "base image archs changed",
+			change: func(c *ConfigFile) {
+				b := c.BaseImages["alma"]
+				b.Archs = []string{"amd64"}
+				c.BaseImages["alma"] = b
+			},
+			expected: configDiff{BaseImages: []string{"alma"}},
+		},
+		{
+			name: "base images added and removed",
+			change: func(c *ConfigFile) {
+				delete(c.BaseImages, "alma")
+				c.BaseImages["fedora"] = Config_BaseImages{Image: "example.com/fedora", Tag: "43"}
+			},
+			expected: configDiff{BaseImages: []string{"alma", "fedora"}},
+		},
+		{
+			name: "containers folder changed",
+			change: func(c *ConfigFile) {
+				c.Folders.Containers = "images"
+			},
+			expected: configDiff{RebuildAllReason: "folders changed in config file"},
+		},
+		{
+			name: "apps folder changed",
+			change: func(c *ConfigFile) {
+				c.Folders.Apps = "packages"
+			},
+			expected: configDiff{RebuildAllReason: "folders changed in config file"},
+		},
+		{
+			name: "container added",
+			change: func(c *ConfigFile) {
+				c.Containers = []string{"desktop", "base", "server"}
+			},
+			expected: configDiff{AddedContainers: []string{"desktop"}},
+		},
+		{
+			// Changes to the list of apps or the order of containers don't require rebuilding anything by themselves
+			name: "unrelated changes",
+			change: func(c *ConfigFile) {
+				c.Containers = []string{"server"}
+				c.Apps = []string{"app2", "app3"}
+			},
+			expected: configDiff{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newC := newConfig()
+			tt.change(newC)
+			res := compareConfigFiles(newConfig(), newC)
+			slices.Sort(res.BaseImages)
+			if !reflect.DeepEqual(*res, tt.expected) {
+				t.Errorf("got %+v, expected %+v", *res, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContainersOnBaseImages(t *testing.T) {
+	config := newTestGraph([]string{"alma", "centos"}, [][2]string{
+		{"base", "default"},
+		{"server", "base"},
+		{"pinned", "centos"},
+		{"on-pinned", "pinned"},
+		{"alma-only", "alma"},
+	})
+
+	tests := []struct {
+		name             string
+		baseImages       []string
+		defaultBaseImage string
+		expected         []string
+	}{
+		{name: "no base images", baseImages: nil, defaultBaseImage: "alma", expected: nil},
+		{name: "default base image changed", baseImages: []string{"alma"}, defaultBaseImage: "alma", expected: []string{"base", "server", "alma-only"}},
+		{name: "other base image changed", baseImages: []string{"alma"}, defaultBaseImage: "centos", expected: []string{"alma-only"}},
+		{name: "pinned base image changed", baseImages: []string{"centos"}, defaultBaseImage: "alma", expected: []string{"pinned", "on-pinned"}},
+		{name: "any default base image", baseImages: []string{"centos"}, defaultBaseImage: "", expected: []string{"base", "server", "pinned", "on-pinned"}},
+		{name: "unrelated base image", baseImages: []string{"fedora"}, defaultBaseImage: "alma", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := config.ContainersOnBaseImages(tt.baseImages, tt.defaultBaseImage)
+			if !slices.Equal(res, tt.expected) || (res == nil) != (tt.expected == nil) {
+				t.Errorf("got %v, expected %v", res, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParentContainer returns the name of the container that the given container is built on top of.
+// Returns an empty string if the container is built on top of a base image.
+func (c *ConfigFile) ParentContainer(name string) string {
+	containerConfig, ok := c.containersMap[name]
+	if !ok || containerConfig == nil {
+		return ""
+	}
+
+	// Base images take precedence over containers, same as when building
+	if _, ok := c.BaseImages[containerConfig.BaseImage]; ok {
+		return ""
+	}
+	if _, ok := c.containersMap[containerConfig.BaseImage]; ok {
+		return containerConfig.BaseImage
+	}
+
+	return ""
+}
+
+// RootContainer returns the name of the container at the root of the chain that ends with the given container.
+// The root container is built on top of a base image.
+func (c *ConfigFile) RootContainer(name string) string {
+	root := name
+	// Limit the number of iterations in case there's a cycle
+	for range len(c.containersMap) {
+		parent := c.ParentContainer(root)
+		if parent == "" {
+			break
+		}
+		root = parent
+	}
+	return root
+}
+
+// AllContainers returns the name of all containers, in topological order.
+func (c *ConfigFile) AllContainers() ([]string, error) {
+	return c.SortContainers(c.containerNames)
+}
+
+// SortContainers returns the containers with the given names sorted in topological order, so each container comes after the ones it's built on top of.
+// Containers that don't depend on each other keep the order in which they are listed in the config file.
+func (c *ConfigFile) SortContainers(names []string) ([]string, error) {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		_, ok := c.containersMap[name]
+		if !ok {
+			return nil, fmt.Errorf("container not found in configuration: %s", name)
+		}
+		selected[name] = true
+	}
+
+	res := make([]string, 0, len(names))
+	visited := make(map[string]bool, len(c.containersMap))
+	for _, name := range c.containerNames {
+		// Walk up the chain of parents, stopping at the first container that was already visited
+		chain := []string{}
+		inChain := map[string]bool{}
+		for cur := name; cur != "" && !visited[cur]; cur = c.ParentContainer(cur) {
+			if inChain[cur] {
+				return nil, fmt.Errorf("found a dependency cycle between containers: %s -> %s", strings.Join(chain, " -> "), cur)
+			}
+			inChain[cur] = true
+			chain = append(chain, cur)
+		}
+
+		// Add containers starting from the root of the chain
+		for i := len(chain) - 1; i >= 0; i-- {
+			visited[chain[i]] = true
+			if selected[chain[i]] {
+				res = append(res, chain[i])
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// ContainerWithDependents returns the container with the given name and all containers that are built on top of it, directly or indirectly, in topological order.
+func (c *ConfigFile) ContainerWithDependents(name string) ([]string, error) {
+	_, ok := c.containersMap[name]
+	if !ok {
+		return nil, fmt.Errorf("container not found in configuration: %s", name)
+	}
+
+	all, err := c.AllContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	// Because the list is sorted, parents are always processed before their children
+	included := map[string]bool{name: true}
+	res := make([]string, 0)
+	for _, cur := range all {
+		if cur == name || included[c.ParentContainer(cur)] {
+			included[cur] = true
+			res = append(res, cur)
+		}
+	}
+
+	return res, nil
+}
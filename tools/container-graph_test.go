@@ -0,0 +1,187 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestGraph returns a config with the base images and the containers, in order.
+// Each container is a pair of its name and the base image or container it's built on top of.
+func newTestGraph(baseImages []string, containers [][2]string) *ConfigFile {
+	config := &ConfigFile{
+		BaseImages:     make(map[string]Config_BaseImages, len(baseImages)),
+		containersMap:  make(map[string]*ContainerConfig, len(containers)),
+		containerNames: make([]string, 0, len(containers)),
+	}
+	for _, b := range baseImages {
+		config.BaseImages[b] = Config_BaseImages{Image: "example.com/" + b}
+	}
+	for _, c := range containers {
+		config.containersMap[c[0]] = &ContainerConfig{ImageName: c[0], BaseImage: c[1]}
+		config.containerNames = append(config.containerNames, c[0])
+	}
+	return config
+}
+
+func TestSortContainers(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers [][2]string
+		selected   []string
+		expected   []string
+		err        string
+	}{
+		{
+			name:       "chain",
+			containers: [][2]string{{"c", "b"}, {"b", "a"}, {"a", "default"}},
+			selected:   []string{"c", "b", "a"},
+			expected:   []string{"a", "b", "c"},
+		},
+		{
+			name:       "siblings keep the order of the config",
+			containers: [][2]string{{"b2", "a"}, {"a", "default"}, {"b1", "a"}},
+			selected:   []string{"b1", "b2", "a"},
+			expected:   []string{"a", "b2", "b1"},
+		},
+		{
+			name:       "subset of a chain",
+			containers: [][2]string{{"c", "b"}, {"b", "a"}, {"a", "default"}},
+			selected:   []string{"c", "a"},
+			expected:   []string{"a", "c"},
+		},
+		{
+			name:       "independent branches",
+			containers: [][2]string{{"x", "default"}, {"a1", "a"}, {"a", "default"}, {"x1", "x"}},
+			selected:   []string{"a1", "x1", "a", "x"},
+			expected:   []string{"x", "a", "a1", "x1"},
+		},
+		{
+			name:       "base image has precedence over a container with the same name",
+			containers: [][2]string{{"b", "default"}, {"default", "alma"}},
+			selected:   []string{"b", "default"},
+			expected:   []string{"b", "default"},
+		},
+		{
+			name:       "missing parent is treated as a base image",
+			containers: [][2]string{{"b", "missing"}, {"a", "default"}},
+			selected:   []string{"a", "b"},
+			expected:   []string{"b", "a"},
+		},
+		{
+			name:       "container not in the config",
+			containers: [][2]string{{"a", "default"}},
+			selected:   []string{"a", "nope"},
+			err:        "container not found in configuration: nope",
+		},
+		{
+			name:       "cycle",
+			containers: [][2]string{{"a", "default"}, {"x", "y"}, {"y", "z"}, {"z", "x"}},
+			selected:   []string{"a"},
+			err:        "found a dependency cycle between containers: x -> y -> z -> x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestGraph([]string{"default", "alma"}, tt.containers)
+			res, err := config.SortContainers(tt.selected)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to sort containers: %v", err)
+			}
+			if !slices.Equal(res, tt.expected) {
+				t.Errorf("got %v, expected %v", res, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContainerWithDependents(t *testing.T) {
+	config := newTestGraph([]string{"default"}, [][2]string{
+		{"a1x", "a1"},
+		{"a", "default"},
+		{"b", "default"},
+		{"a1", "a"},
+		{"a2", "a"},
+		{"b1", "b"},
+	})
+
+	tests := []struct {
+		from     string
+		expected []string
+		err      string
+	}{
+		{from: "a", expected: []string{"a", "a1", "a1x", "a2"}},
+		{from: "a1", expected: []string{"a1", "a1x"}},
+		{from: "a1x", expected: []string{"a1x"}},
+		{from: "b", expected: []string{"b", "b1"}},
+		{from: "nope", err: "container not found in configuration: nope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			res, err := config.ContainerWithDependents(tt.from)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get dependents: %v", err)
+			}
+			if !slices.Equal(res, tt.expected) {
+				t.Errorf("got %v, expected %v", res, tt.expected)
+			}
+		})
+	}
+
+	// With a cycle anywhere in the config, the order can't be determined
+	cyclic := newTestGraph([]string{"default"}, [][2]string{{"a", "default"}, {"x", "y"}, {"y", "x"}})
+	_, err := cyclic.ContainerWithDependents("a")
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+}
+
+func TestParentContainer(t *testing.T) {
+	config := newTestGraph([]string{"default"}, [][2]string{
+		{"a", "default"},
+		{"b", "a"},
+		{"c", "b"},
+		{"orphan", "missing"},
+		{"x", "y"},
+		{"y", "x"},
+	})
+
+	tests := []struct {
+		name   string
+		parent string
+		root   string
+	}{
+		{name: "a", parent: "", root: "a"},
+		{name: "b", parent: "a", root: "a"},
+		{name: "c", parent: "b", root: "a"},
+		{name: "orphan", parent: "", root: "orphan"},
+		{name: "nope", parent: "", root: "nope"},
+		// Cycles must not cause an infinite loop
+		{name: "x", parent: "y", root: "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if parent := config.ParentContainer(tt.name); parent != tt.parent {
+				t.Errorf("got parent '%s', expected '%s'", parent, tt.parent)
+			}
+			if root := config.RootContainer(tt.name); root != tt.root {
+				t.Errorf("got root '%s', expected '%s'", root, tt.root)
+			}
+		})
+	}
+}
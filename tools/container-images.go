@@ -4,19 +4,34 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/platform"
 	"github.com/regclient/regclient/types/ref"
 )
 
+// Label with the version of the software in the image, defined by the OCI image spec
+const imageVersionLabel = "org.opencontainers.image.version"
+
+// contextWithDefaultTimeout returns a context with the timeout, unless the parent context already has a deadline.
+func contextWithDefaultTimeout(parentCtx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := parentCtx.Deadline(); ok {
+		return context.WithCancel(parentCtx)
+	}
+	return context.WithTimeout(parentCtx, timeout)
+}
+
 func getImageDigest(parentCtx context.Context, registryClient *regclient.RegClient, image string) (string, error) {
 	r, err := ref.New(image)
 	if err != nil {
 		return "", errors.New("failed to create reference")
 	}
 
-	ctx, cancel := context.WithTimeout(parentCtx, 30*time.Second)
+	ctx, cancel := contextWithDefaultTimeout(parentCtx, 30*time.Second)
 	defer cancel()
 	manifest, err := registryClient.ManifestGet(ctx, r)
 	if err != nil {
@@ -25,3 +40,107 @@ func getImageDigest(parentCtx context.Context, registryClient *regclient.RegClie
 
 	return manifest.GetDescriptor().Digest.String(), nil
 }
+
+// getImagePlatforms returns the platforms the image is available for.
+// For multi-platform images, these are the platforms in the index; otherwise, it's the platform in the config of the image.
+func getImagePlatforms(parentCtx context.Context, registryClient *regclient.RegClient, image string) ([]platform.Platform, error) {
+	r, err := ref.New(image)
+	if err != nil {
+		return nil, errors.New("failed to create reference")
+	}
+
+	ctx, cancel := contextWithDefaultTimeout(parentCtx, 30*time.Second)
+	defer cancel()
+	m, err := registryClient.ManifestGet(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve manifest: %w", err)
+	}
+
+	// Single-platform image
+	if !m.IsList() {
+		cfg, err := registryClient.ImageConfig(ctx, r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve image config: %w", err)
+		}
+		return []platform.Platform{cfg.GetConfig().Platform}, nil
+	}
+
+	idx, ok := m.(manifest.Indexer)
+	if !ok {
+		return nil, errors.New("manifest is a list, but not an index")
+	}
+	descs, err := idx.GetManifestList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	res := make([]platform.Platform, 0, len(descs))
+	for _, d := range descs {
+		// Entries without a platform, such as attestations, are ignored
+		if d.Platform != nil {
+			res = append(res, *d.Platform)
+		}
+	}
+	return res, nil
+}
+
+// missingArchPlatforms returns the platforms for the archs, such as "linux/arm64", that are not in available.
+// Only the OS and the architecture are compared, so "linux/arm64" matches "linux/arm64/v8".
+func missingArchPlatforms(available []platform.Platform, archs []string) []string {
+	res := make([]string, 0)
+	for _, arch := range archs {
+		found := slices.ContainsFunc(available, func(p platform.Platform) bool {
+			return p.OS == "linux" && p.Architecture == arch
+		})
+		if !found {
+			res = append(res, "linux/"+arch)
+		}
+	}
+	return res
+}
+
+// checkImagePlatforms returns an error if the image is not available for all the archs, which lists the platforms that are missing.
+func checkImagePlatforms(available []platform.Platform, image string, archs []string) error {
+	missing := missingArchPlatforms(available, archs)
+	if len(missing) > 0 {
+		return fmt.Errorf("image '%s' does not contain platform(s) %s", image, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// getImageLabels returns the labels in the config of the image for the given platform, such as "linux/amd64".
+func getImageLabels(parentCtx context.Context, registryClient *regclient.RegClient, image string, platform string) (map[string]string, error) {
+	r, err := ref.New(image)
+	if err != nil {
+		return nil, errors.New("failed to create reference")
+	}
+
+	ctx, cancel := context.WithTimeout(parentCtx, 30*time.Second)
+	defer cancel()
+	cfg, err := registryClient.ImageConfig(ctx, r, regclient.ImageWithPlatform(platform))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve image config: %w", err)
+	}
+
+	return cfg.GetConfig().Config.Labels, nil
+}
+
+// copyImageTag adds a tag to an image that exists in the registry, without pulling it.
+func copyImageTag(parentCtx context.Context, registryClient *regclient.RegClient, image string, target string) error {
+	src, err := ref.New(image)
+	if err != nil {
+		return errors.New("failed to create reference")
+	}
+	tgt, err := ref.New(target)
+	if err != nil {
+		return errors.New("failed to create reference")
+	}
+
+	ctx, cancel := context.WithTimeout(parentCtx, 2*time.Minute)
+	defer cancel()
+	err = registryClient.ImageCopy(ctx, src, tgt)
+	if err != nil {
+		return fmt.Errorf("failed to copy image: %w", err)
+	}
+
+	return nil
+}
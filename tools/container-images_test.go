@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/types/platform"
+)
+
+func TestMissingArchPlatforms(t *testing.T) {
+	available := []platform.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		{OS: "windows", Architecture: "s390x"},
+		// Attestations in the index
+		{OS: "unknown", Architecture: "unknown"},
+	}
+
+	tests := []struct {
+		name     string
+		archs    []string
+		expected []string
+		err      string
+	}{
+		{name: "all available", archs: []string{"amd64", "arm64"}, expected: []string{}},
+		{name: "variant is ignored", archs: []string{"arm64"}, expected: []string{}},
+		{name: "missing arch", archs: []string{"amd64", "ppc64le"}, expected: []string{"linux/ppc64le"}, err: "image 'example.com/base:10' does not contain platform(s) linux/ppc64le"},
+		{name: "only for other OSes", archs: []string{"s390x", "riscv64"}, expected: []string{"linux/s390x", "linux/riscv64"}, err: "image 'example.com/base:10' does not contain platform(s) linux/s390x, linux/riscv64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing := missingArchPlatforms(available, tt.archs)
+			if !slices.Equal(missing, tt.expected) {
+				t.Errorf("got missing platforms %v, expected %v", missing, tt.expected)
+			}
+
+			err := checkImagePlatforms(available, "example.com/base:10", tt.archs)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || err.Error() != tt.err {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestGetImagePlatformsIndex(t *testing.T) {
+	// Index with an image for amd64 and an attestation, but no image for arm64
+	const index = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+      "size": 1000,
+      "platform": {"architecture": "amd64", "os": "linux"}
+    },
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+      "size": 1000,
+      "annotations": {"vnd.docker.reference.type": "attestation-manifest"}
+    }
+  ]
+}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		case "/v2/library/base/manifests/10":
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			_, _ = w.Write([]byte(index))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	rc := regclient.New(regclient.WithConfigHost(config.Host{
+		Name: host,
+		TLS:  config.TLSDisabled,
+	}))
+	image := host + "/library/base:10"
+	available, err := getImagePlatforms(context.Background(), rc, image)
+	if err != nil {
+		t.Fatalf("failed to get platforms: %v", err)
+	}
+	if len(available) != 1 || available[0].OS != "linux" || available[0].Architecture != "amd64" {
+		t.Errorf("unexpected platforms %v", available)
+	}
+
+	err = checkImagePlatforms(available, image, []string{"amd64", "arm64"})
+	expected := "image '" + image + "' does not contain platform(s) linux/arm64"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// containerInput is a file or folder that is used to build a container.
+type containerInput struct {
+	// Absolute path to the file or folder
+	Path string
+	// If the input belongs to an app included in the container, the name of the app
+	App string
+}
+
+// Matches returns true if the file at the given absolute path is the input, or is inside the input's folder.
+func (i containerInput) Matches(path string) bool {
+	return path == i.Path || strings.HasPrefix(path, i.Path+string(filepath.Separator))
+}
+
+// ContainerInputs returns the files and folders used to build the container with the given name.
+// These include the container's configuration, its Containerfile and build context, and the configuration and Containerfiles of all apps it includes.
+// The container it's built on top of is not included.
+func (c *ConfigFile) ContainerInputs(name string) ([]containerInput, error) {
+	containerConfig, ok := c.containersMap[name]
+	if !ok {
+		return nil, fmt.Errorf("container not found in configuration: %s", name)
+	}
+
+	containerDir := containerConfig.Dir()
+	res := []containerInput{
+		{Path: containerConfig.SavePath},
+		{Path: filepath.Join(containerDir, "container.override.yaml")},
+		{Path: containerConfig.Containerfile},
+		{Path: containerConfig.BuildContext},
+	}
+
+	for _, appName := range containerConfig.Apps {
+		app, ok := c.appsMap[appName]
+		if !ok {
+			return nil, fmt.Errorf("container references app '%s', which is not defined in config", appName)
+		}
+
+		appDir := app.Dir()
+		res = append(res,
+			containerInput{Path: app.SavePath, App: appName},
+			containerInput{Path: filepath.Join(appDir, "app.override.yaml"), App: appName},
+			containerInput{Path: filepath.Join(appDir, app.Containerfile), App: appName},
+		)
+		for _, bcf := range app.BuilderContainerfiles {
+			res = append(res, containerInput{Path: filepath.Join(appDir, bcf), App: appName})
+		}
+	}
+
+	// Make all paths absolute, so they can be compared with the changed files
+	for i := range res {
+		p, err := filepath.Abs(res[i].Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path of '%s': %w", res[i].Path, err)
+		}
+		res[i].Path = p
+	}
+
+	return res, nil
+}
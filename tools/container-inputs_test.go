@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestContainerInputsMatches(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml": testBaseImagesConfig + `containers:
+  - base
+  - server
+apps:
+  - tool
+  - lib
+  - other
+`,
+		"containers/base/container.yaml":   "imageName: base\nbaseImage: default\napps:\n  - tool\n  - lib\n",
+		"containers/base/Containerfile":    "FROM ${BASE_IMAGE}\n",
+		"containers/base/files/etc/motd":   "hello\n",
+		"containers/server/container.yaml": "imageName: server\nbaseImage: base\napps:\n  - lib\nbuildContext: ../../shared\n",
+		"containers/server/Containerfile":  "FROM ${BASE_IMAGE}\n",
+		"shared/scripts/setup.sh":          "#!/bin/sh\n",
+		"apps/tool/app.yaml":               "name: tool\nversion: 1.0.0\nbuilderContainerfiles:\n  - builder/Containerfile\n",
+		"apps/tool/Containerfile":          "RUN echo tool\n",
+		"apps/tool/builder/Containerfile":  "FROM scratch AS tool-builder\n",
+		"apps/tool/README.md":              "tool\n",
+		"apps/lib/app.yaml":                "name: lib\nversion: 1.0.0\n",
+		"apps/lib/Containerfile":           "RUN echo lib\n",
+		"apps/other/app.yaml":              "name: other\nversion: 1.0.0\n",
+		"apps/other/Containerfile":         "RUN echo other\n",
+	})
+
+	config, err := LoadConfigFile(dir, "config.yaml", "")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	inputs := map[string][]containerInput{}
+	for _, name := range []string{"base", "server"} {
+		inputs[name], err = config.ContainerInputs(name)
+		if err != nil {
+			t.Fatalf("failed to get inputs for '%s': %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		file string
+		// Matching containers, with the app after a colon if the file belongs to an app
+		expected []string
+	}{
+		// Files of the container
+		{file: "containers/base/container.yaml", expected: []string{"base"}},
+		{file: "containers/base/container.override.yaml", expected: []string{"base"}},
+		{file: "containers/base/Containerfile", expected: []string{"base"}},
+		{file: "containers/base/files/etc/motd", expected: []string{"base"}},
+		{file: "containers/server/Containerfile", expected: []string{"server"}},
+		// Folders whose name starts with the name of the container's folder
+		{file: "containers/base-extra/Containerfile", expected: nil},
+		// Build context outside of the container's folder
+		{file: "shared/scripts/setup.sh", expected: []string{"server"}},
+		// Apps used by the containers, including one used by both
+		{file: "apps/tool/app.yaml", expected: []string{"base:tool"}},
+		{file: "apps/tool/app.override.yaml", expected: []string{"base:tool"}},
+		{file: "apps/tool/builder/Containerfile", expected: []string{"base:tool"}},
+		{file: "apps/lib/Containerfile", expected: []string{"base:lib", "server:lib"}},
+		// Files in an app that are not used to build it
+		{file: "apps/tool/README.md", expected: nil},
+		// Files in an app that is not used by any container
+		{file: "apps/other/Containerfile", expected: nil},
+		{file: "apps/other/app.yaml", expected: nil},
+		// The config file is handled separately
+		{file: "config.yaml", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			var res []string
+			for _, name := range []string{"base", "server"} {
+				for _, input := range inputs[name] {
+					if !input.Matches(path) {
+						continue
+					}
+					match := name
+					if input.App != "" {
+						match += ":" + input.App
+					}
+					if !slices.Contains(res, match) {
+						res = append(res, match)
+					}
+				}
+			}
+			if !slices.Equal(res, tt.expected) {
+				t.Errorf("got %v, expected %v", res, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// gitChange is a file changed between two revisions.
+type gitChange struct {
+	// Status letter, as returned by "git diff --name-status", such as "A", "M", "D", "R"
+	Status string
+	// Path of the file, relative to the root of the repository
+	Path string
+	// For renames and copies, the path of the source file
+	OldPath string
+}
+
+// runGit runs git in the given directory and returns its standard output.
+func runGit(dir string, args ...string) ([]byte, error) {
+	out := &bytes.Buffer{}
+	err := runProcess(runProcessOpts{
+		Name:      "git",
+		Args:      args,
+		Stdout:    out,
+		Dir:       dir,
+		NoConsole: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run 'git %s': %w", strings.Join(args, " "), err)
+	}
+	return out.Bytes(), nil
+}
+
+// gitRepoRoot returns the absolute path to the root of the repository that contains dir.
+func gitRepoRoot(dir string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitRevisionExists returns true if rev resolves to a commit in the repository.
+func gitRevisionExists(dir string, rev string) bool {
+	_, err := runGit(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	return err == nil
+}
+
+// gitChangedFiles returns the list of files that changed between the two revisions.
+func gitChangedFiles(dir string, baseRef string, headRef string) ([]gitChange, error) {
+	out, err := runGit(dir, "diff", "--name-status", "-z", "--find-renames", baseRef, headRef)
+	if err != nil {
+		return nil, err
+	}
+	return parseGitNameStatus(out)
+}
+
+// parseGitNameStatus parses the output of "git diff --name-status -z".
+func parseGitNameStatus(out []byte) ([]gitChange, error) {
+	// With -z, fields are separated by NUL characters: the status, then one path (or two for renames and copies)
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	res := make([]gitChange, 0, len(fields)/2)
+	for i := 0; i < len(fields) && fields[i] != ""; {
+		status := fields[i][:1]
+		i++
+
+		change := gitChange{Status: status}
+		if status == "R" || status == "C" {
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("unexpected output from git diff for status '%s'", status)
+			}
+			change.OldPath = fields[i]
+			change.Path = fields[i+1]
+			i += 2
+		} else {
+			if i >= len(fields) {
+				return nil, fmt.Errorf("unexpected output from git diff for status '%s'", status)
+			}
+			change.Path = fields[i]
+			i++
+		}
+		res = append(res, change)
+	}
+
+	return res, nil
+}
+
+// gitReadFile returns the content of a file at the given revision.
+// The path is relative to dir.
+// If the file doesn't exist at that revision, the second return value is false.
+func gitReadFile(dir string, rev string, path string) ([]byte, bool, error) {
+	obj := rev + ":./" + filepath.ToSlash(path)
+	_, err := runGit(dir, "cat-file", "-e", obj)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	out, err := runGit(dir, "show", obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
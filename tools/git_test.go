@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGitNameStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		out      string
+		expected []gitChange
+		err      string
+	}{
+		{
+			name:     "empty",
+			out:      "",
+			expected: []gitChange{},
+		},
+		{
+			name: "added, modified, deleted",
+			out:  "A\x00apps/new/app.yaml\x00M\x00config.yaml\x00D\x00containers/old/Containerfile\x00",
+			expected: []gitChange{
+				{Status: "A", Path: "apps/new/app.yaml"},
+				{Status: "M", Path: "config.yaml"},
+				{Status: "D", Path: "containers/old/Containerfile"},
+			},
+		},
+		{
+			name: "renames and copies have two paths",
+			out:  "R100\x00apps/a/old.sh\x00apps/a/new.sh\x00M\x00config.yaml\x00C075\x00apps/a/new.sh\x00apps/b/copy.sh\x00",
+			expected: []gitChange{
+				{Status: "R", OldPath: "apps/a/old.sh", Path: "apps/a/new.sh"},
+				{Status: "M", Path: "config.yaml"},
+				{Status: "C", OldPath: "apps/a/new.sh", Path: "apps/b/copy.sh"},
+			},
+		},
+		{
+			name: "paths with spaces and newlines",
+			out:  "M\x00apps/a/with space.txt\x00A\x00apps/a/new\nline\x00",
+			expected: []gitChange{
+				{Status: "M", Path: "apps/a/with space.txt"},
+				{Status: "A", Path: "apps/a/new\nline"},
+			},
+		},
+		{
+			name: "missing path",
+			out:  "M\x00config.yaml\x00D\x00",
+			err:  "unexpected output from git diff for status 'D'",
+		},
+		{
+			name: "missing destination of a rename",
+			out:  "R100\x00apps/a/old.sh\x00",
+			err:  "unexpected output from git diff for status 'R'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseGitNameStatus([]byte(tt.out))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("got %+v, expected %+v", res, tt.expected)
+			}
+		})
+	}
+}
+
+// newTestGitRepo creates a git repository in dir, and returns a function that runs git in it.
+// The test is skipped if git is not installed.
+func newTestGitRepo(t *testing.T, dir string) func(args ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("test requires git")
+	}
+
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := runGit(dir, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet")
+	return git
+}
+
+func TestGitChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	git := newTestGitRepo(t, dir)
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml":                "containers: []\n",
+		"apps/a/install.sh":          "#!/bin/sh\necho 'installing app a, with enough content to be detected as a rename'\n",
+		"apps/b/old.txt":             "to be deleted\n",
+		"containers/c/Containerfile": "FROM scratch\n",
+	})
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "base")
+	base := git("rev-parse", "HEAD")
+
+	err := os.Rename(filepath.Join(dir, "apps/a/install.sh"), filepath.Join(dir, "apps/a/setup.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(filepath.Join(dir, "apps/b/old.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml":    "containers:\n  - c\n",
+		"apps/b/new.txt": "added\n",
+	})
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "head")
+
+	res, err := gitChangedFiles(dir, base, "HEAD")
+	if err != nil {
+		t.Fatalf("failed to get changed files: %v", err)
+	}
+	expected := []gitChange{
+		{Status: "R", OldPath: "apps/a/install.sh", Path: "apps/a/setup.sh"},
+		{Status: "A", Path: "apps/b/new.txt"},
+		{Status: "D", Path: "apps/b/old.txt"},
+		{Status: "M", Path: "config.yaml"},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("got %+v, expected %+v", res, expected)
+	}
+}
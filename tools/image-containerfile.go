@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,15 +10,21 @@ import (
 )
 
 type Containerfile struct {
-	// Working directory
+	// Working directory, used to show relative paths in source markers
 	WorkDir string
-	// Name of the container to build
-	Container string
+	// Configuration of the container to build
+	Container *ContainerConfig
 	// List of additional apps
 	Apps []*App
+	// If true, each section is preceded by a comment with the path of the file it comes from
+	SourceMarkers bool
 }
 
 func (c *Containerfile) BuildContainerfile() (io.Reader, error) {
+	if c.Container == nil {
+		return nil, errors.New("container configuration is required")
+	}
+
 	// Result is a buffer
 	res := &bytes.Buffer{}
 
@@ -27,35 +34,52 @@ func (c *Containerfile) BuildContainerfile() (io.Reader, error) {
 			continue
 		}
 
-		appPath := filepath.Join(c.WorkDir, "apps", app.Name)
+		appPath := app.Dir()
 		for _, bcf := range app.BuilderContainerfiles {
-			data, err := os.ReadFile(filepath.Join(appPath, bcf))
+			bcfPath := filepath.Join(appPath, bcf)
+			data, err := os.ReadFile(bcfPath)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read builder Containerfile '%s' for app '%s': %w", bcf, app.Name, err)
 			}
-			res.Write(data)
-			res.WriteRune('\n')
+			c.writeSection(res, bcfPath, data)
 		}
 	}
 
 	// Load the Containerfile for this image
-	baseContainerfilePath := filepath.Join(c.WorkDir, "containers", c.Container, "Containerfile")
+	// The path is already resolved when loading the container's configuration
+	baseContainerfilePath := c.Container.Containerfile
 	baseContainerfile, err := os.ReadFile(baseContainerfilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read base Containerfile '%s': %w", baseContainerfilePath, err)
 	}
-	res.Write(baseContainerfile)
-	res.WriteRune('\n')
+	c.writeSection(res, baseContainerfilePath, baseContainerfile)
 
 	// Append containerfiles for apps
 	for _, app := range c.Apps {
-		data, err := os.ReadFile(filepath.Join(c.WorkDir, "apps", app.Name, app.Containerfile))
+		appContainerfilePath := filepath.Join(app.Dir(), app.Containerfile)
+		data, err := os.ReadFile(appContainerfilePath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read Containerfile for app '%s': %w", app.Name, err)
 		}
-		res.Write(data)
-		res.WriteRune('\n')
+		c.writeSection(res, appContainerfilePath, data)
 	}
 
 	return res, nil
 }
+
+// writeSection appends the content of a file to the Containerfile, with a marker if enabled.
+func (c *Containerfile) writeSection(res *bytes.Buffer, path string, data []byte) {
+	if c.SourceMarkers {
+		// Show paths relative to the working directory when possible
+		workDir, err := filepath.Abs(c.WorkDir)
+		if err == nil {
+			rel, err := filepath.Rel(workDir, path)
+			if err == nil {
+				path = rel
+			}
+		}
+		fmt.Fprintf(res, "# ----- Source: %s -----\n", filepath.ToSlash(path))
+	}
+	res.Write(data)
+	res.WriteRune('\n')
+}
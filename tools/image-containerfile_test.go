@@ -0,0 +1,222 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPrepareContainerBuild(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		container string
+		// Expected effective Containerfile
+		containerfile string
+		// Expected value of the BASE_IMAGE build arg
+		baseImage string
+		// Expected name of the parent image, if any
+		parentImage string
+	}{
+		{
+			name: "default folders",
+			files: map[string]string{
+				"config.yaml": testBaseImagesConfig + `containers:
+  - base
+apps:
+  - tool
+`,
+				"containers/base/container.yaml": "imageName: base\nbaseImage: default\napps:\n  - tool\n",
+				"containers/base/Containerfile":  "FROM ${BASE_IMAGE}\nRUN echo base",
+				"apps/tool/app.yaml":             "name: tool\nversion: 1.0.0\n",
+				"apps/tool/Containerfile":        "RUN echo tool",
+			},
+			container:     "base",
+			containerfile: "FROM ${BASE_IMAGE}\nRUN echo base\nRUN echo tool\n",
+			baseImage:     "example.com/test/base@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			name: "custom folders",
+			files: map[string]string{
+				"config.yaml": testBaseImagesConfig + `folders:
+  apps: my/apps
+  containers: images
+containers:
+  - base
+apps:
+  - tool
+`,
+				"images/base/container.yaml": "imageName: base\nbaseImage: default\napps:\n  - tool\n",
+				"images/base/Containerfile":  "FROM ${BASE_IMAGE}\nRUN echo base",
+				"my/apps/tool/app.yaml":      "name: tool\nversion: 1.0.0\n",
+				"my/apps/tool/Containerfile": "RUN echo tool",
+				// Files in the default folders must not be used
+				"containers/base/Containerfile": "RUN echo wrong",
+				"apps/tool/Containerfile":       "RUN echo wrong",
+			},
+			container:     "base",
+			containerfile: "FROM ${BASE_IMAGE}\nRUN echo base\nRUN echo tool\n",
+			baseImage:     "example.com/test/base@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			name: "custom containerfile",
+			files: map[string]string{
+				"config.yaml": testBaseImagesConfig + `containers:
+  - base
+  - other
+`,
+				"containers/base/container.yaml":             "imageName: base\nbaseImage: default\ncontainerfile: build/Containerfile.custom\n",
+				"containers/base/build/Containerfile.custom": "FROM ${BASE_IMAGE}\nRUN echo custom",
+				"containers/base/Containerfile":              "RUN echo wrong",
+				"containers/other/container.yaml":            "imageName: other\nbaseImage: default\n",
+				"containers/other/Containerfile":             "FROM ${BASE_IMAGE}\nRUN echo other",
+			},
+			container:     "base",
+			containerfile: "FROM ${BASE_IMAGE}\nRUN echo custom\n",
+			baseImage:     "example.com/test/base@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			name: "default containerfile next to a custom one",
+			files: map[string]string{
+				"config.yaml": testBaseImagesConfig + `containers:
+  - base
+  - other
+`,
+				"containers/base/container.yaml":             "imageName: base\nbaseImage: default\ncontainerfile: build/Containerfile.custom\n",
+				"containers/base/build/Containerfile.custom": "FROM ${BASE_IMAGE}\nRUN echo custom",
+				"containers/other/container.yaml":            "imageName: other\nbaseImage: default\n",
+				"containers/other/Containerfile":             "FROM ${BASE_IMAGE}\nRUN echo other",
+			},
+			container:     "other",
+			containerfile: "FROM ${BASE_IMAGE}\nRUN echo other\n",
+			baseImage:     "example.com/test/base@sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			name: "image name different from the folder",
+			files: map[string]string{
+				"config.yaml": testBaseImagesConfig + `containers:
+  - base-folder
+  - server-folder
+`,
+				"containers/base-folder/container.yaml":   "imageName: base\nbaseImage: default\n",
+				"containers/base-folder/Containerfile":    "FROM ${BASE_IMAGE}\nRUN echo base",
+				"containers/server-folder/container.yaml": "imageName: server\nbaseImage: base\n",
+				"containers/server-folder/Containerfile":  "FROM ${BASE_IMAGE}\nRUN echo server",
+			},
+			container:     "server",
+			containerfile: "FROM ${BASE_IMAGE}\nRUN echo server\n",
+			baseImage:     "example.com/repo/base:latest",
+			parentImage:   "example.com/repo/base",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tt.files)
+
+			config, err := LoadConfigFile(dir, "config.yaml", "")
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			flags := &buildFlags{
+				WorkDir:          dir,
+				DefaultBaseImage: "test-base",
+				Platform:         "podman",
+				Repository:       "example.com/repo",
+				Archs:            []string{"amd64"},
+			}
+
+			build, err := prepareContainerBuild(flags, tt.container, config, false)
+			if err != nil {
+				t.Fatalf("failed to prepare build: %v", err)
+			}
+			if build.SkipReason != "" {
+				t.Fatalf("container is skipped: %s", build.SkipReason)
+			}
+
+			if string(build.Containerfile) != tt.containerfile {
+				t.Errorf("wrong Containerfile:\n%s\nexpected:\n%s", build.Containerfile, tt.containerfile)
+			}
+			if !slices.Contains(build.BuildArgs, "BASE_IMAGE="+tt.baseImage) {
+				t.Errorf("missing BASE_IMAGE=%s in build args: %v", tt.baseImage, build.BuildArgs)
+			}
+			if build.ParentImage != tt.parentImage {
+				t.Errorf("wrong parent image '%s', expected '%s'", build.ParentImage, tt.parentImage)
+			}
+			if !strings.HasPrefix(build.ManifestNameTag, flags.buildImageName(tt.container)+":") {
+				t.Errorf("manifest '%s' does not use the image name '%s'", build.ManifestNameTag, tt.container)
+			}
+		})
+	}
+}
+
+func TestPrepareContainerBuildImageNameNotFolder(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml":                           testBaseImagesConfig + "containers:\n  - base-folder\n",
+		"containers/base-folder/container.yaml": "imageName: base\nbaseImage: default\n",
+		"containers/base-folder/Containerfile":  "FROM ${BASE_IMAGE}",
+	})
+
+	config, err := LoadConfigFile(dir, "config.yaml", "")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	flags := &buildFlags{
+		WorkDir:          dir,
+		DefaultBaseImage: "test-base",
+		Platform:         "podman",
+		Repository:       "example.com/repo",
+		Archs:            []string{"amd64"},
+	}
+
+	// Containers are referenced by their image name, not by their folder
+	_, err = prepareContainerBuild(flags, "base-folder", config, false)
+	if err == nil {
+		t.Error("expected an error when referencing the container by its folder")
+	}
+	if c := config.ContainerByFolder("base-folder"); c == nil || c.ImageName != "base" {
+		t.Errorf("container not found by folder: %v", c)
+	}
+}
+
+func TestBuildContainerfileSourceMarkers(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"containers/base/Containerfile":   "FROM ${BASE_IMAGE}",
+		"apps/tool/Containerfile.builder": "FROM scratch AS tool-builder",
+		"apps/tool/Containerfile":         "RUN echo tool",
+	})
+
+	containerfile := Containerfile{
+		WorkDir: dir,
+		Container: &ContainerConfig{
+			Containerfile: filepath.Join(dir, "containers/base/Containerfile"),
+		},
+		Apps: []*App{{
+			Name:                  "tool",
+			Containerfile:         "Containerfile",
+			BuilderContainerfiles: []string{"Containerfile.builder"},
+			SavePath:              filepath.Join(dir, "apps/tool/app.yaml"),
+		}},
+		SourceMarkers: true,
+	}
+	r, err := containerfile.BuildContainerfile()
+	if err != nil {
+		t.Fatalf("failed to build Containerfile: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read Containerfile: %v", err)
+	}
+
+	expected := "# ----- Source: apps/tool/Containerfile.builder -----\nFROM scratch AS tool-builder\n" +
+		"# ----- Source: containers/base/Containerfile -----\nFROM ${BASE_IMAGE}\n" +
+		"# ----- Source: apps/tool/Containerfile -----\nRUN echo tool\n"
+	if string(data) != expected {
+		t.Errorf("wrong Containerfile:\n%s\nexpected:\n%s", data, expected)
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -12,8 +15,16 @@ var rootCmd = &cobra.Command{
 	Short: "Tools for the italypaleale/bootc repo",
 }
 
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&yamlStrict, "strict-yaml", true, "Reject unknown properties in config, container, and app files; set to false to ignore them")
+	rootCmd.PersistentFlags().BoolVar(&yamlValidateSchema, "validate-schema", false, "Validate config, container, and app files against their JSON Schema when loading them")
+}
+
 func main() {
-	err := rootCmd.Execute()
+	// Cancel the context on Ctrl-C or SIGTERM, which stops running processes such as builds
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	cancel()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
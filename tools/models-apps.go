@@ -2,18 +2,20 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
-	"os"
+	"path/filepath"
 )
 
 type App struct {
-	Name                  string    `yaml:"name,omitempty"`
-	Containerfile         string    `yaml:"containerfile,omitempty"`
-	BuilderContainerfiles []string  `yaml:"builderContainerfiles,omitempty"`
-	Version               string    `yaml:"version,omitempty"`
-	Checksums             string    `yaml:"checksums,omitempty"`
-	Cmds                  *App_Cmds `yaml:"cmds,omitempty"`
-	IgnoredVersions       []string  `yaml:"ignoredVersions,omitempty"`
+	Name                  string            `yaml:"name,omitempty" description:"Name of the app, used by containers to include it"`
+	Containerfile         string            `yaml:"containerfile,omitempty" description:"Path to the Containerfile appended to the container's, relative to the app's folder" default:"Containerfile"`
+	BuilderContainerfiles []string          `yaml:"builderContainerfiles,omitempty" description:"Paths to Containerfiles with builder stages, added before the container's, relative to the app's folder"`
+	Version               string            `yaml:"version,omitempty" description:"Version of the app, passed as the VERSION_<NAME> build arg"`
+	Checksums             string            `yaml:"checksums,omitempty" description:"Checksums of the app's files, one per line in the format '<sha256>  <filename>', passed as the CHECKSUMS_<NAME> build arg"`
+	Source                *App_Source       `yaml:"source,omitempty" description:"Source of the latest version of the app, used instead of 'cmds.updateVersion'"`
+	Artifacts             []App_Artifact    `yaml:"artifacts,omitempty" description:"Files downloaded by the app's Containerfiles, whose checksums are updated together with the version"`
+	Cmds                  *App_Cmds         `yaml:"cmds,omitempty" description:"Shell scripts used to update the app"`
+	IgnoredVersions       []string          `yaml:"ignoredVersions,omitempty" description:"Versions that are never used when updating the app; entries can be versions, globs such as '1.84.*', or regular expressions wrapped in slashes such as '/^1\\.9[0-9]\\./'"`
+	UpdatePolicy          *App_UpdatePolicy `yaml:"updatePolicy,omitempty" description:"Rules that new versions must follow to be used when updating the app"`
 
 	SavePath string `yaml:"-"`
 }
@@ -29,8 +31,8 @@ func LoadApp(fileName string, overrideFileName string) (*App, error) {
 	}
 
 	if overrideFileName != "" {
-		err = loadYamlFile(app, overrideFileName)
-		if err != nil && !errors.Is(err, os.ErrNotExist) {
+		_, err = loadYamlFileIfExists(app, overrideFileName)
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -38,12 +40,41 @@ func LoadApp(fileName string, overrideFileName string) (*App, error) {
 	return app, nil
 }
 
+// Dir returns the folder that contains the app's configuration.
+func (a App) Dir() string {
+	return filepath.Dir(a.SavePath)
+}
+
 func (a App) String() string {
 	j, _ := json.Marshal(a)
 	return string(j)
 }
 
 type App_Cmds struct {
-	UpdateVersion   string `yaml:"updateVersion,omitempty"`
-	UpdateChecksums string `yaml:"updateChecksums,omitempty"`
+	UpdateVersion   string `yaml:"updateVersion,omitempty" description:"Script that prints the latest version of the app, used if 'source' is not set"`
+	UpdateChecksums string `yaml:"updateChecksums,omitempty" description:"Script that prints the checksums for the latest version of the app, used if 'artifacts' is not set"`
+}
+
+type App_Source struct {
+	Type        string `yaml:"type,omitempty" description:"Type of the source" enum:"github-release,pypi,rpm-repo,container-tag"`
+	Repo        string `yaml:"repo,omitempty" description:"For 'github-release': repository, in the format 'owner/name'; the version is the tag of the latest release"`
+	Package     string `yaml:"package,omitempty" description:"For 'pypi' and 'rpm-repo': name of the package"`
+	URL         string `yaml:"url,omitempty" description:"For 'rpm-repo': base URL of the repository, which contains the 'repodata' folder"`
+	Image       string `yaml:"image,omitempty" description:"For 'container-tag': name of the image, without tag; the version is the highest tag"`
+	Match       string `yaml:"match,omitempty" description:"For 'container-tag': regular expression that tags must match to be considered; defaults to tags with numbers separated by dots, optionally prefixed by 'v'"`
+	StripPrefix string `yaml:"stripPrefix,omitempty" description:"Prefix removed from the version, such as 'v'"`
+}
+
+type App_Artifact struct {
+	URL          string   `yaml:"url,omitempty" description:"Template for the URL of the file, which can use {{.Version}}, {{.Arch}} (such as 'amd64'), and {{.Machine}} (such as 'x86_64')"`
+	ChecksumsURL string   `yaml:"checksumsUrl,omitempty" description:"Template for the URL of the upstream checksum file listing the file; if empty, the file is downloaded to compute its checksum"`
+	Name         string   `yaml:"name,omitempty" description:"Template for the name of the file in the checksums; defaults to the last part of the URL"`
+	Archs        []string `yaml:"archs,omitempty" description:"Architectures to fetch the file for; if empty, the file is not architecture-specific" enum:"amd64,arm64"`
+	Verify       bool     `yaml:"verify,omitempty" description:"If true, the file is downloaded and its checksum is compared with the one in the checksum file"`
+}
+
+type App_UpdatePolicy struct {
+	Constraint      string `yaml:"constraint,omitempty" description:"Semantic version constraint that new versions must satisfy, such as '~1.36' or '<2.0'"`
+	AllowPrerelease bool   `yaml:"allowPrerelease,omitempty" description:"If true, pre-release versions (such as '2.0.0-rc1') can be used"`
+	AllowDowngrade  bool   `yaml:"allowDowngrade,omitempty" description:"If true, versions lower than the current one can be used"`
 }
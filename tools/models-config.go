@@ -1,24 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 
 	"gopkg.in/yaml.v3"
 )
 
 type ConfigFile struct {
-	BaseImages map[string]Config_BaseImages `yaml:"baseImages,omitempty"`
-	Folders    Config_Folders               `yaml:"folders,omitempty"`
-	Containers []string                     `yaml:"containers,omitempty"`
-	Apps       []string                     `yaml:"apps,omitempty"`
-
-	SavePath      string `yaml:"-"`
-	containersMap map[string]*ContainerConfig
-	appsMap       map[string]*App
+	BaseImages map[string]Config_BaseImages `yaml:"baseImages,omitempty" description:"Base images that containers can be built on top of, keyed by name"`
+	Folders    Config_Folders               `yaml:"folders,omitempty" description:"Folders containing the apps and the containers, relative to the config file"`
+	Containers []string                     `yaml:"containers,omitempty" description:"Names of the folders of the containers to build"`
+	Apps       []string                     `yaml:"apps,omitempty" description:"Names of the folders of the apps that can be added to containers"`
+
+	SavePath       string `yaml:"-"`
+	containersMap  map[string]*ContainerConfig
+	containerNames []string
+	appsMap        map[string]*App
 }
 
 func (c ConfigFile) String() string {
@@ -27,14 +31,15 @@ func (c ConfigFile) String() string {
 }
 
 type Config_BaseImages struct {
-	Image  string `yaml:"image,omitempty"`
-	Tag    string `yaml:"tag,omitempty"`
-	Digest string `yaml:"digest,omitempty"`
+	Image  string   `yaml:"image,omitempty" description:"Name of the image, without tag"`
+	Tag    string   `yaml:"tag,omitempty" description:"Tag of the image, used to look up the latest digest"`
+	Digest string   `yaml:"digest,omitempty" description:"Digest of the image that containers are built on" pattern:"^sha256:[0-9a-f]{64}$"`
+	Archs  []string `yaml:"archs,omitempty" description:"Architectures supported by the image; if empty, all architectures are supported" enum:"amd64,arm64"`
 }
 
 type Config_Folders struct {
-	Apps       string `yaml:"apps,omitempty"`
-	Containers string `yaml:"containers,omitempty"`
+	Apps       string `yaml:"apps,omitempty" description:"Folder containing the apps" default:"apps"`
+	Containers string `yaml:"containers,omitempty" description:"Folder containing the containers" default:"containers"`
 
 	// Parsed Apps
 	AppsDir string `yaml:"-"`
@@ -42,13 +47,46 @@ type Config_Folders struct {
 	ContainersDir string `yaml:"-"`
 }
 
+// LoadConfigFile loads the config file in the work dir, with the containers and apps it lists.
+// If there are problems, the returned error includes all of them.
 func LoadConfigFile(workDir string, configFileName string, overrideFileName string) (*ConfigFile, error) {
 	if configFileName == "" {
 		configFileName = "config.yaml"
 	}
-	configFile := filepath.Join(workDir, configFileName)
-	fmt.Fprintf(os.Stderr, "Loading config file: %s\n", configFile)
+	fmt.Fprintf(os.Stderr, "Loading config file: %s\n", filepath.Join(workDir, configFileName))
+
+	config, problems := loadConfig(workDir, configFileName, overrideFileName)
+	if len(problems) > 0 {
+		errs := make([]error, len(problems))
+		for i, p := range problems {
+			errs[i] = errors.New(p.String())
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	return config, nil
+}
 
+// validationProblem is a problem found in a file.
+type validationProblem struct {
+	File    string
+	Message string
+}
+
+func (p validationProblem) String() string {
+	return p.File + ": " + p.Message
+}
+
+// loadConfig loads the config file in the work dir, with the override file if set, and the containers and apps it lists.
+// Instead of stopping at the first problem, it returns all problems found; containers and apps that can't be loaded are not included in the config.
+// If the config file itself can't be loaded, the returned config is nil.
+func loadConfig(workDir string, configFileName string, overrideFileName string) (*ConfigFile, []validationProblem) {
+	problems := make([]validationProblem, 0)
+	addProblem := func(file string, format string, a ...any) {
+		problems = append(problems, validationProblem{File: file, Message: fmt.Sprintf(format, a...)})
+	}
+
+	configFile := filepath.Join(workDir, configFileName)
 	config := &ConfigFile{
 		Folders: Config_Folders{
 			Apps:       "apps",
@@ -58,60 +96,97 @@ func LoadConfigFile(workDir string, configFileName string, overrideFileName stri
 	}
 	err := loadYamlFile(config, configFile)
 	if err != nil {
-		return nil, err
+		addProblem(configFile, "failed to load file: %v", err)
+		return nil, problems
 	}
 
 	// Load the override file if present
 	if overrideFileName != "" {
-		err = loadYamlFile(config, filepath.Join(workDir, overrideFileName))
-		if err != nil && !errors.Is(err, os.ErrNotExist) {
-			return nil, err
+		overrideFile := filepath.Join(workDir, overrideFileName)
+		_, err = loadYamlFileIfExists(config, overrideFile)
+		if err != nil {
+			addProblem(overrideFile, "failed to load file: %v", err)
+			return nil, problems
 		}
 	}
 
 	// Clean and validate the folders
 	if config.Folders.Apps == "" {
-		return nil, errors.New("required property 'folders.apps' is empty")
-	}
-	config.Folders.AppsDir, err = filepath.Abs(filepath.Join(workDir, config.Folders.Apps))
-	if err != nil {
-		return nil, fmt.Errorf("invalid path for 'folders.apps': %w", err)
+		addProblem(configFile, "required property 'folders.apps' is empty")
+	} else {
+		config.Folders.AppsDir, err = filepath.Abs(filepath.Join(workDir, config.Folders.Apps))
+		if err != nil {
+			addProblem(configFile, "invalid path for 'folders.apps': %v", err)
+		}
 	}
 	if config.Folders.Containers == "" {
-		return nil, errors.New("required property 'folders.containers' is empty")
-	}
-	config.Folders.ContainersDir, err = filepath.Abs(filepath.Join(workDir, config.Folders.Containers))
-	if err != nil {
-		return nil, fmt.Errorf("invalid path for 'folders.containers': %w", err)
+		addProblem(configFile, "required property 'folders.containers' is empty")
+	} else {
+		config.Folders.ContainersDir, err = filepath.Abs(filepath.Join(workDir, config.Folders.Containers))
+		if err != nil {
+			addProblem(configFile, "invalid path for 'folders.containers': %v", err)
+		}
 	}
 
 	// Load the containers
 	config.containersMap = make(map[string]*ContainerConfig, len(config.Containers))
+	config.containerNames = make([]string, 0, len(config.Containers))
 	for _, c := range config.Containers {
+		if config.Folders.ContainersDir == "" {
+			break
+		}
+		containerFile := filepath.Join(config.Folders.ContainersDir, c, "container.yaml")
 		container, err := LoadContainerConfig(
-			filepath.Join(config.Folders.ContainersDir, c, "container.yaml"),
+			containerFile,
 			filepath.Join(config.Folders.ContainersDir, c, "container.override.yaml"),
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load container configuration for container '%s': %w", c, err)
+			// Report each problem separately
+			errs := []error{err}
+			var joined interface{ Unwrap() []error }
+			if errors.As(err, &joined) {
+				errs = joined.Unwrap()
+			}
+			for _, e := range errs {
+				addProblem(containerFile, "failed to load container configuration for container '%s': %v", c, e)
+			}
+			continue
+		}
+		if other, ok := config.containersMap[container.ImageName]; ok {
+			addProblem(containerFile, "image name '%s' is also used by '%s'", container.ImageName, other.SavePath)
+			continue
 		}
 		config.containersMap[container.ImageName] = container
+		config.containerNames = append(config.containerNames, container.ImageName)
 	}
 
 	// Load the apps
 	config.appsMap = make(map[string]*App, len(config.Apps))
 	for _, a := range config.Apps {
+		if config.Folders.AppsDir == "" {
+			break
+		}
+		appFile := filepath.Join(config.Folders.AppsDir, a, "app.yaml")
 		app, err := LoadApp(
-			filepath.Join(config.Folders.AppsDir, a, "app.yaml"),
+			appFile,
 			filepath.Join(config.Folders.AppsDir, a, "app.override.yaml"),
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load app configuration for app '%s': %w", a, err)
+			addProblem(appFile, "failed to load app configuration for app '%s': %v", a, err)
+			continue
+		}
+		if app.Name == "" {
+			addProblem(appFile, "property 'name' is required")
+			continue
+		}
+		if other, ok := config.appsMap[app.Name]; ok {
+			addProblem(appFile, "app name '%s' is also used by '%s'", app.Name, other.SavePath)
+			continue
 		}
 		config.appsMap[app.Name] = app
 	}
 
-	return config, nil
+	return config, problems
 }
 
 func loadYamlFile(dest any, fileName string) error {
@@ -121,7 +196,7 @@ func loadYamlFile(dest any, fileName string) error {
 	}
 	defer f.Close()
 
-	err = yaml.NewDecoder(f).Decode(dest)
+	err = decodeYaml(dest, f, fileName)
 	if err != nil {
 		return fmt.Errorf("error reading file: %w", err)
 	}
@@ -129,19 +204,100 @@ func loadYamlFile(dest any, fileName string) error {
 	return nil
 }
 
-func saveYamlFile(obj any, savePath string) error {
-	f, err := os.Create(savePath)
+// loadYamlFileIfExists loads the YAML file into dest if the file exists.
+// Returns false if the file doesn't exist.
+func loadYamlFileIfExists(dest any, fileName string) (bool, error) {
+	_, err := os.Stat(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error checking file: %w", err)
+	}
+
+	return true, loadYamlFile(dest, fileName)
+}
+
+var (
+	// If true, YAML files are validated against the JSON Schema for their type when loaded
+	yamlValidateSchema bool
+	// If true, unknown properties in YAML files are errors
+	yamlStrict = true
+)
+
+// decodeYaml decodes the YAML document from r into dest.
+// Errors include fileName and the position of the error in the file.
+func decodeYaml(dest any, r io.Reader, fileName string) error {
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return fmt.Errorf("error opening file for writing: %w", err)
+		return err
+	}
+
+	// Parse into a node first, which is used to find the position of errors and to validate against the schema
+	var node yaml.Node
+	err = yaml.Unmarshal(data, &node)
+	if err != nil {
+		return newYamlErrors(err, fileName, nil)
+	}
+	if node.Kind == 0 {
+		return yamlError{File: fileName, Message: "file is empty"}
+	}
+
+	if yamlValidateSchema {
+		s, err := schemaForType(reflect.TypeOf(dest))
+		if err != nil {
+			return fmt.Errorf("failed to generate schema: %w", err)
+		}
+		errs := validateYamlSchema(&node, s, fileName)
+		if len(errs) > 0 {
+			return fmt.Errorf("file does not match the schema: %w", errors.Join(errs...))
+		}
 	}
-	defer f.Close()
 
-	enc := yaml.NewEncoder(f)
-	enc.SetIndent(2)
-	err = enc.Encode(obj)
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(yamlStrict)
+	err = dec.Decode(dest)
 	if err != nil {
-		return fmt.Errorf("error writing to file: %w", err)
+		return newYamlErrors(err, fileName, &node)
 	}
 
 	return nil
 }
+
+// ContainerByFolder returns the configuration for the container in the given folder, or nil if there's none.
+func (c *ConfigFile) ContainerByFolder(folder string) *ContainerConfig {
+	for _, containerConfig := range c.containersMap {
+		if filepath.Base(containerConfig.Dir()) == folder {
+			return containerConfig
+		}
+	}
+	return nil
+}
+
+// FindWorkDirs returns the folders inside root that contain a config.yaml file, sorted by name.
+// The returned paths are relative to root.
+func FindWorkDirs(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s': %w", root, err)
+	}
+
+	res := make([]string, 0)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		_, err = os.Stat(filepath.Join(root, e.Name(), "config.yaml"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to check for config file in '%s': %w", e.Name(), err)
+		}
+		res = append(res, e.Name())
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no folder with a config.yaml file found in '%s'", root)
+	}
+
+	return res, nil
+}
@@ -9,11 +9,14 @@ import (
 )
 
 type ContainerConfig struct {
-	Containerfile string   `yaml:"containerfile"`
-	BuildContext  string   `yaml:"buildContext"`
-	ImageName     string   `yaml:"imageName"`
-	BaseImage     string   `yaml:"baseImage"`
-	Apps          []string `yaml:"apps"`
+	Containerfile     string   `yaml:"containerfile" description:"Path to the Containerfile, relative to the container's folder" default:"Containerfile"`
+	BuildContext      string   `yaml:"buildContext" description:"Path to the build context, relative to the container's folder" default:"."`
+	ImageName         string   `yaml:"imageName" description:"Name of the image that is built"`
+	BaseImage         string   `yaml:"baseImage" description:"Name of a base image from the config file, 'default' for the default base image, or name of another container to build on top of"`
+	Apps              []string `yaml:"apps" description:"Names of the apps to add to the container"`
+	Archs             []string `yaml:"archs" description:"Architectures the container supports; if empty, all architectures supported by the base image" enum:"amd64,arm64"`
+	OnlyBaseImages    []string `yaml:"onlyBaseImages" description:"If set, the container is built only when the chain is rooted at one of these base images"`
+	ExcludeBaseImages []string `yaml:"excludeBaseImages" description:"The container is not built when the chain is rooted at one of these base images"`
 
 	SavePath string `yaml:"-"`
 }
@@ -32,8 +35,8 @@ func LoadContainerConfig(fileName string, overrideFileName string) (*ContainerCo
 	}
 
 	if overrideFileName != "" {
-		err = loadYamlFile(config, overrideFileName)
-		if err != nil && !errors.Is(err, os.ErrNotExist) {
+		_, err = loadYamlFileIfExists(config, overrideFileName)
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -47,31 +50,42 @@ func LoadContainerConfig(fileName string, overrideFileName string) (*ContainerCo
 }
 
 func (c *ContainerConfig) Validate(basePath string) error {
+	errs := make([]error, 0)
+
 	// Ensure the Containerfile exists
 	if c.Containerfile == "" {
-		return errors.New("property 'containerfile' is required")
-	}
-	c.Containerfile = filepath.Join(basePath, c.Containerfile)
-	if _, err := os.Stat(c.Containerfile); errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("containerfile '%s' does not exist", c.Containerfile)
+		errs = append(errs, errors.New("property 'containerfile' is required"))
+	} else {
+		c.Containerfile = filepath.Join(basePath, c.Containerfile)
+		if _, err := os.Stat(c.Containerfile); errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("containerfile '%s' does not exist", c.Containerfile))
+		}
 	}
 
-	// Normalize build context
+	// Normalize build context, which must exist too
 	if c.BuildContext == "" || c.BuildContext == "." {
 		c.BuildContext = basePath
 	} else {
 		c.BuildContext = filepath.Join(basePath, c.BuildContext)
 	}
+	if _, err := os.Stat(c.BuildContext); errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("build context '%s' does not exist", c.BuildContext))
+	}
 
 	// Ensure required fields are set
 	if c.BaseImage == "" {
-		return errors.New("property 'baseImage' is required")
+		errs = append(errs, errors.New("property 'baseImage' is required"))
 	}
 	if c.ImageName == "" {
-		return errors.New("property 'imageName' is required")
+		errs = append(errs, errors.New("property 'imageName' is required"))
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// Dir returns the folder that contains the container's configuration.
+func (c ContainerConfig) Dir() string {
+	return filepath.Dir(c.SavePath)
 }
 
 func (c ContainerConfig) String() string {
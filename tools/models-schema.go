@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonSchema is a JSON Schema, supporting the subset of keywords needed to describe the config files.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Default              any                    `json:"default,omitempty"`
+
+	// Compiled Pattern
+	patternExp *regexp.Regexp
+}
+
+func (s jsonSchema) String() string {
+	j, _ := json.MarshalIndent(s, "", "  ")
+	return string(j)
+}
+
+// Schemas for the config files, keyed by the name used by the schema command
+var configSchemaTypes = map[string]struct {
+	title string
+	value any
+}{
+	"config":    {"config.yaml", ConfigFile{}},
+	"container": {"container.yaml", ContainerConfig{}},
+	"app":       {"app.yaml", App{}},
+}
+
+// Cache of schemas generated for each type
+var schemaCache sync.Map
+
+// schemaForType returns the JSON Schema for the given struct type, generated from the struct's fields and tags.
+// Fields are named after their "yaml" tag; the "description", "default", "enum" (comma-separated), and "pattern" tags add the corresponding keywords.
+// It returns an error if the tags are invalid, such as a pattern that is not a valid regular expression.
+func schemaForType(t reflect.Type) (*jsonSchema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	cached, ok := schemaCache.Load(t)
+	if ok {
+		return cached.(*jsonSchema), nil
+	}
+
+	s, err := buildSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schemaCache.Store(t, s)
+	return s, nil
+}
+
+func buildSchema(t reflect.Type) (*jsonSchema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		s := &jsonSchema{
+			Type:                 "object",
+			Properties:           make(map[string]*jsonSchema),
+			AdditionalProperties: false,
+		}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+
+			prop, err := buildSchema(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			prop.Description = field.Tag.Get("description")
+			if pattern := field.Tag.Get("pattern"); pattern != "" {
+				prop.Pattern = pattern
+				prop.patternExp, err = regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("invalid pattern for field '%s' of type '%s': %w", field.Name, t.Name(), err)
+				}
+			}
+			if def := field.Tag.Get("default"); def != "" {
+				prop.Default = def
+			}
+			if enum := field.Tag.Get("enum"); enum != "" {
+				// For lists, the enum applies to the items
+				target := prop
+				if prop.Type == "array" {
+					target = prop.Items
+				}
+				target.Enum = strings.Split(enum, ",")
+			}
+			s.Properties[name] = prop
+		}
+		return s, nil
+	case reflect.Map:
+		elem, err := buildSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{
+			Type:                 "object",
+			AdditionalProperties: elem,
+		}, nil
+	case reflect.Slice, reflect.Array:
+		elem, err := buildSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{
+			Type:  "array",
+			Items: elem,
+		}, nil
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}, nil
+	default:
+		return &jsonSchema{Type: "string"}, nil
+	}
+}
+
+// validateYamlSchema validates a YAML document against the schema, returning all errors found.
+// Errors include the file name, and the line and column of the node.
+func validateYamlSchema(node *yaml.Node, s *jsonSchema, fileName string) []error {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+
+	errs := make([]error, 0)
+	validateYamlNode(node, s, "", fileName, &errs)
+	return errs
+}
+
+func validateYamlNode(node *yaml.Node, s *jsonSchema, path string, fileName string, errs *[]error) {
+	addError := func(format string, a ...any) {
+		msg := fmt.Sprintf(format, a...)
+		if path != "" {
+			msg = fmt.Sprintf("property '%s': %s", path, msg)
+		}
+		*errs = append(*errs, yamlError{File: fileName, Line: node.Line, Column: node.Column, Message: msg})
+	}
+
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	// Empty values are decoded as zero values
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			addError("must be an object")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			propPath := key
+			if path != "" {
+				propPath = path + "." + key
+			}
+
+			if prop, ok := s.Properties[key]; ok {
+				validateYamlNode(value, prop, propPath, fileName, errs)
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case *jsonSchema:
+				validateYamlNode(value, additional, propPath, fileName, errs)
+			default:
+				*errs = append(*errs, yamlError{
+					File:    fileName,
+					Line:    node.Content[i].Line,
+					Column:  node.Content[i].Column,
+					Message: fmt.Sprintf("unknown property '%s'", propPath),
+				})
+			}
+		}
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			addError("must be a list")
+			return
+		}
+		for i, item := range node.Content {
+			validateYamlNode(item, s.Items, fmt.Sprintf("%s[%d]", path, i), fileName, errs)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			addError("must be a %s", s.Type)
+			return
+		}
+		// Unquoted values such as "10" or "1.2" are decoded into strings too
+		expectedTags := map[string][]string{
+			"string":  {"!!str", "!!int", "!!float", "!!bool", "!!timestamp"},
+			"boolean": {"!!bool"},
+			"integer": {"!!int"},
+			"number":  {"!!int", "!!float"},
+		}[s.Type]
+		if len(expectedTags) > 0 && !slices.Contains(expectedTags, node.Tag) {
+			addError("must be a %s", s.Type)
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, node.Value) {
+			addError("must be one of: %s", strings.Join(s.Enum, ", "))
+		}
+		if s.patternExp != nil && !s.patternExp.MatchString(node.Value) {
+			addError("must match the pattern '%s'", s.Pattern)
+		}
+	}
+}
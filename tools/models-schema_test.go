@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type testSchemaObject struct {
+	Tag      string            `yaml:"tag"`
+	Version  string            `yaml:"version"`
+	Enabled  bool              `yaml:"enabled"`
+	Count    int               `yaml:"count"`
+	Ratio    float64           `yaml:"ratio"`
+	Archs    []string          `yaml:"archs" enum:"amd64,arm64"`
+	Labels   map[string]string `yaml:"labels"`
+	Checksum string            `yaml:"checksum" pattern:"^sha256:[0-9a-f]+$"`
+}
+
+func TestValidateYamlSchema(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		// Substrings of the expected errors, in order
+		errs []string
+	}{
+		{name: "quoted strings", input: "tag: \"10\"\nversion: '1.2'\n"},
+		{name: "unquoted int for a string", input: "tag: 10\n"},
+		{name: "unquoted float for a string", input: "version: 1.2\n"},
+		{name: "unquoted bool for a string", input: "tag: true\n"},
+		{name: "unquoted date for a string", input: "version: 2026-10-18\n"},
+		{name: "empty value", input: "tag:\ncount:\n"},
+		{name: "numbers", input: "count: 3\nratio: 1.5\n"},
+		{name: "integer for a number", input: "ratio: 2\n"},
+		{name: "string for a bool", input: "enabled: yes please\n", errs: []string{"test.yaml:1:10: property 'enabled': must be a boolean"}},
+		{name: "float for an integer", input: "count: 1.5\n", errs: []string{"property 'count': must be a integer"}},
+		{name: "list for a string", input: "tag:\n  - a\n", errs: []string{"property 'tag': must be a string"}},
+		{name: "enum", input: "archs: [amd64, s390x]\n", errs: []string{"property 'archs[1]': must be one of: amd64, arm64"}},
+		{name: "pattern", input: "checksum: md5:abc\n", errs: []string{"property 'checksum': must match the pattern"}},
+		{name: "map values", input: "labels:\n  a: 1\n  b: [x]\n", errs: []string{"property 'labels.b': must be a string"}},
+		{name: "unknown property", input: "tags: 10\n", errs: []string{"unknown property 'tags'"}},
+	}
+
+	s, err := schemaForType(reflect.TypeOf(testSchemaObject{}))
+	if err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node yaml.Node
+			err := yaml.Unmarshal([]byte(tt.input), &node)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			errs := validateYamlSchema(&node, s, "test.yaml")
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("expected %d errors, got %v", len(tt.errs), errs)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.errs[i]) {
+					t.Errorf("error %q does not contain %q", err, tt.errs[i])
+				}
+			}
+
+			// Values accepted by the schema must be decoded without errors
+			if len(tt.errs) == 0 {
+				var obj testSchemaObject
+				err = node.Decode(&obj)
+				if err != nil {
+					t.Errorf("failed to decode: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaForTypeInvalidPattern(t *testing.T) {
+	type invalidPattern struct {
+		Items []struct {
+			Name string `yaml:"name" pattern:"^[a-z+$"`
+		} `yaml:"items"`
+	}
+
+	_, err := schemaForType(reflect.TypeOf(invalidPattern{}))
+	if err == nil || !strings.Contains(err.Error(), "invalid pattern for field 'Name'") {
+		t.Errorf("expected an error for the invalid pattern, got %v", err)
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
 type runProcessOpts struct {
@@ -15,25 +19,46 @@ type runProcessOpts struct {
 	Stdout    io.Writer
 	Stdin     io.Reader
 	NoConsole bool
+	// Writer for the console output; defaults to os.Stderr
+	Console io.Writer
+	// Working directory for the process; defaults to the current one
+	Dir string
+	// If set, when the context is done the process and all its children are killed
+	Context context.Context
 }
 
 func runProcess(opts runProcessOpts) error {
+	console := opts.Console
+	if console == nil {
+		console = os.Stderr
+	}
+
 	if !opts.NoConsole {
-		fmt.Fprintf(os.Stderr, "Executing: %s %s\n", opts.Name, strings.Join(opts.Args, " "))
+		fmt.Fprintf(console, "Executing: %s %s\n", opts.Name, strings.Join(opts.Args, " "))
 	}
 
 	cmd := exec.Command(opts.Name, opts.Args...)
+	if opts.Context != nil {
+		cmd = exec.CommandContext(opts.Context, opts.Name, opts.Args...)
+		// Kill the whole process group, which includes processes started by shell scripts
+		cmd.Cancel = func() error {
+			return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		// Don't wait forever for output from processes that outlived the group
+		cmd.WaitDelay = 5 * time.Second
+	}
+	cmd.Dir = opts.Dir
 
 	if opts.NoConsole {
 		cmd.Stdout = opts.Stdout
 	} else if opts.Stdout == nil {
-		// Redirect all output to stderr
-		cmd.Stdout = os.Stderr
-		cmd.Stderr = os.Stderr
+		// Redirect all output to the console
+		cmd.Stdout = console
+		cmd.Stderr = console
 	} else {
-		// Redirect all output to stderr too in addition to what the user requested
-		cmd.Stdout = io.MultiWriter(os.Stderr, opts.Stdout)
-		cmd.Stderr = os.Stderr
+		// Redirect all output to the console too in addition to what the user requested
+		cmd.Stdout = io.MultiWriter(console, opts.Stdout)
+		cmd.Stderr = console
 	}
 
 	if opts.Stdin != nil {
@@ -47,11 +72,78 @@ func runProcess(opts runProcessOpts) error {
 	return cmd.Run()
 }
 
-func runShellScript(script string, stdout io.Writer, noConsole bool) error {
+// runShellScript runs the script with bash; when the context is done, the script and all processes it started are killed.
+func runShellScript(ctx context.Context, script string, stdout io.Writer, noConsole bool) error {
 	return runProcess(runProcessOpts{
 		Name:      "/bin/bash",
 		Args:      []string{"-c", script},
 		Stdout:    stdout,
 		NoConsole: noConsole,
+		Context:   ctx,
 	})
 }
+
+// prefixWriter is an io.Writer that adds a prefix to every line written to the underlying writer.
+// Multiple prefixWriter objects can share the same lock, so lines written by each of them are not interleaved.
+type prefixWriter struct {
+	out    io.Writer
+	prefix []byte
+	lock   *sync.Mutex
+
+	buf     []byte
+	bufLock sync.Mutex
+}
+
+func newPrefixWriter(out io.Writer, prefix string, lock *sync.Mutex) *prefixWriter {
+	return &prefixWriter{
+		out:    out,
+		prefix: []byte(prefix),
+		lock:   lock,
+	}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.bufLock.Lock()
+	defer w.bufLock.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	// Write all complete lines
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	err := w.writeLines(w.buf[:i+1])
+	w.buf = w.buf[i+1:]
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Flush writes any partial line that is still buffered.
+func (w *prefixWriter) Flush() error {
+	w.bufLock.Lock()
+	defer w.bufLock.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeLines(append(w.buf, '\n'))
+	w.buf = nil
+	return err
+}
+
+func (w *prefixWriter) writeLines(data []byte) error {
+	out := make([]byte, 0, len(data)+len(w.prefix)*(bytes.Count(data, []byte{'\n'})))
+	for line := range bytes.Lines(data) {
+		out = append(out, w.prefix...)
+		out = append(out, line...)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err := w.out.Write(out)
+	return err
+}
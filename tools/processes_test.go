@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// processRunning returns true if the process exists and is not a zombie.
+func processRunning(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, which is in parentheses
+	_, after, ok := strings.Cut(string(stat), ") ")
+	return ok && !strings.HasPrefix(after, "Z")
+}
+
+func TestRunProcessContextKillsGroup(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("test requires /proc")
+	}
+
+	// The script starts a child process that would outlive it if only the script was killed
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := runShellScript(ctx, "sleep 60 & echo $! > '"+pidFile+"'; wait", nil, true)
+	if err == nil {
+		t.Fatal("expected an error when the context is done")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("process was stopped after %v", elapsed)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("failed to read pid: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("invalid pid: %v", err)
+	}
+
+	// Killing is asynchronous
+	deadline := time.Now().Add(2 * time.Second)
+	for processRunning(pid) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if processRunning(pid) {
+		t.Errorf("child process %d is still running", pid)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that is safe for concurrent writes, like the console.
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestRunProcessOutput(t *testing.T) {
+	var stdout bytes.Buffer
+	var console lockedBuffer
+	err := runProcess(runProcessOpts{
+		Context: context.Background(),
+		Name:    "/bin/sh",
+		Args:    []string{"-c", "echo out; echo err >&2"},
+		Stdout:  &stdout,
+		Console: &console,
+	})
+	if err != nil {
+		t.Fatalf("failed to run process: %v", err)
+	}
+	if stdout.String() != "out\n" {
+		t.Errorf("wrong stdout %q", stdout.String())
+	}
+	// The console has the command, and both stdout and stderr
+	for _, s := range []string{"Executing: /bin/sh -c", "out\n", "err\n"} {
+		if !strings.Contains(console.String(), s) {
+			t.Errorf("console output %q does not contain %q", console.String(), s)
+		}
+	}
+}
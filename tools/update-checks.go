@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// permanentError is an error that doesn't go away by retrying, such as an invalid configuration or a missing resource.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// updateCheckOptions controls how update checks are run.
+type updateCheckOptions struct {
+	// Maximum number of checks running at the same time
+	Jobs int
+	// Timeout for each attempt of a check
+	Timeout time.Duration
+	// Number of times a failed check is retried
+	Retries int
+	// Delay before the first retry, doubled for each following one
+	Backoff time.Duration
+}
+
+// updateCheck is a check for updates, such as for the version of an app.
+type updateCheck struct {
+	// Name shown in the logs
+	Name string
+	// Run the check, writing logs to log
+	// The function is invoked again if the check is retried, so it must not keep state between attempts
+	Run func(ctx context.Context, log io.Writer) error
+}
+
+// runUpdateChecks runs the checks, up to opts.Jobs at the same time.
+// Each attempt is limited by opts.Timeout, and failed checks are retried with backoff unless the error is permanent.
+// A failed check doesn't stop the others; the returned slice contains the error of each check, or nil if it succeeded.
+func runUpdateChecks(ctx context.Context, checks []updateCheck, opts updateCheckOptions) []error {
+	errs := make([]error, len(checks))
+
+	// Lock shared by all writers to the console, so lines are not interleaved
+	var consoleLock sync.Mutex
+	prefixLen := 0
+	for _, c := range checks {
+		prefixLen = max(prefixLen, len(c.Name))
+	}
+
+	sem := make(chan struct{}, max(opts.Jobs, 1))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Logs are prefixed with the name of the check only when checks run in parallel
+			var log io.Writer = os.Stderr
+			if opts.Jobs > 1 {
+				pw := newPrefixWriter(os.Stderr, fmt.Sprintf("[%-*s] ", prefixLen, c.Name), &consoleLock)
+				defer pw.Flush()
+				log = pw
+			}
+
+			errs[i] = runUpdateCheck(ctx, c, opts, log)
+			if errs[i] != nil {
+				fmt.Fprintf(log, "Check failed: %v\n", errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func runUpdateCheck(ctx context.Context, check updateCheck, opts updateCheckOptions, log io.Writer) error {
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		err := check.Run(attemptCtx, log)
+		timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+		cancel()
+
+		switch {
+		case err == nil:
+			return nil
+		case timedOut:
+			err = fmt.Errorf("timed out after %v: %w", opts.Timeout, err)
+		}
+
+		var permanent permanentError
+		if attempt >= opts.Retries || errors.As(err, &permanent) || ctx.Err() != nil {
+			return err
+		}
+
+		fmt.Fprintf(log, "Attempt %d failed, retrying in %v: %v\n", attempt+1, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunUpdateChecksRetries(t *testing.T) {
+	opts := updateCheckOptions{
+		Jobs:    2,
+		Timeout: time.Second,
+		Retries: 2,
+		Backoff: time.Millisecond,
+	}
+
+	tests := []struct {
+		name string
+		// Errors returned by each attempt; after the last one, the check succeeds
+		attemptErrs []error
+		attempts    int
+		err         string
+	}{
+		{
+			name:     "success",
+			attempts: 1,
+		},
+		{
+			name:        "transient failures",
+			attemptErrs: []error{errors.New("connection reset"), errors.New("connection reset")},
+			attempts:    3,
+		},
+		{
+			name:        "retries exhausted",
+			attemptErrs: []error{errors.New("try 1"), errors.New("try 2"), errors.New("try 3"), errors.New("try 4")},
+			attempts:    3,
+			err:         "try 3",
+		},
+		{
+			name:        "permanent error",
+			attemptErrs: []error{permanentError{errors.New("not found")}},
+			attempts:    1,
+			err:         "not found",
+		},
+		{
+			name:        "wrapped permanent error",
+			attemptErrs: []error{fmt.Errorf("failed to check: %w", permanentError{errors.New("not found")})},
+			attempts:    1,
+			err:         "failed to check: not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			check := updateCheck{
+				Name: "app",
+				Run: func(ctx context.Context, log io.Writer) error {
+					n := int(attempts.Add(1))
+					if n <= len(tt.attemptErrs) {
+						return tt.attemptErrs[n-1]
+					}
+					return nil
+				},
+			}
+
+			errs := runUpdateChecks(context.Background(), []updateCheck{check}, opts)
+			if int(attempts.Load()) != tt.attempts {
+				t.Errorf("check ran %d times, expected %d", attempts.Load(), tt.attempts)
+			}
+			if tt.err == "" {
+				if errs[0] != nil {
+					t.Errorf("unexpected error: %v", errs[0])
+				}
+			} else if errs[0] == nil || errs[0].Error() != tt.err {
+				t.Errorf("expected error %q, got %v", tt.err, errs[0])
+			}
+		})
+	}
+}
+
+func TestRunUpdateChecksTimeout(t *testing.T) {
+	opts := updateCheckOptions{
+		Jobs:    1,
+		Timeout: 50 * time.Millisecond,
+		Retries: 1,
+		Backoff: time.Millisecond,
+	}
+
+	// The first attempt hangs until it's cancelled, and the retry succeeds
+	var attempts atomic.Int32
+	hangOnce := updateCheck{
+		Name: "hang-once",
+		Run: func(ctx context.Context, log io.Writer) error {
+			if attempts.Add(1) == 1 {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		},
+	}
+	// All attempts hang
+	hang := updateCheck{
+		Name: "hang",
+		Run: func(ctx context.Context, log io.Writer) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	start := time.Now()
+	errs := runUpdateChecks(context.Background(), []updateCheck{hangOnce, hang}, opts)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("hung checks were stopped after %v", elapsed)
+	}
+
+	if errs[0] != nil {
+		t.Errorf("unexpected error for the check that hung once: %v", errs[0])
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("check ran %d times, expected 2", attempts.Load())
+	}
+	if !errors.Is(errs[1], context.DeadlineExceeded) || !strings.HasPrefix(errs[1].Error(), "timed out after 50ms") {
+		t.Errorf("expected a timeout error, got %v", errs[1])
+	}
+}
+
+func TestRunUpdateChecksJobs(t *testing.T) {
+	const jobs = 3
+	var (
+		lock       sync.Mutex
+		running    int
+		maxRunning int
+	)
+	checks := make([]updateCheck, 8)
+	for i := range checks {
+		checks[i] = updateCheck{
+			Name: fmt.Sprintf("app%d", i),
+			Run: func(ctx context.Context, log io.Writer) error {
+				lock.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				lock.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				lock.Lock()
+				running--
+				lock.Unlock()
+
+				// A failed check doesn't stop the others
+				if i == 0 {
+					return permanentError{errors.New("failed")}
+				}
+				return nil
+			},
+		}
+	}
+
+	errs := runUpdateChecks(context.Background(), checks, updateCheckOptions{Jobs: jobs, Timeout: time.Second})
+	for i, err := range errs {
+		if (i == 0) != (err != nil) {
+			t.Errorf("unexpected result for check %d: %v", i, err)
+		}
+	}
+	if maxRunning != jobs {
+		t.Errorf("%d checks ran at the same time, expected %d", maxRunning, jobs)
+	}
+}
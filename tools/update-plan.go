@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Decisions for the items in an update plan
+type updateDecision string
+
+const (
+	// The value is updated to the candidate
+	updateDecisionUpdate updateDecision = "update"
+	// The candidate is the same as the current value
+	updateDecisionUnchanged updateDecision = "unchanged"
+	// The candidate is in the list of ignored versions
+	updateDecisionIgnored updateDecision = "ignored"
+	// The candidate is not allowed by the update policy
+	updateDecisionHeldBack updateDecision = "held-back"
+	// The candidate doesn't meet the requirements of the config, such as a base image that doesn't contain all required platforms
+	updateDecisionRejected updateDecision = "rejected"
+	// The check for updates failed
+	updateDecisionFailed updateDecision = "failed"
+)
+
+// updatePlan contains the updates found by update-versions for one or more work dirs, which can be applied later.
+// Items are grouped by base image and by app, with an entry for each work dir that contains them.
+type updatePlan struct {
+	// Root of the repository, as passed to the command that created the plan
+	// Files in the plan are relative to it; when the plan is applied, they're resolved against the root passed to that command instead
+	Root string `json:"root"`
+	// Work dirs that were checked, relative to the root
+	WorkDirs   []string              `json:"workDirs"`
+	BaseImages []updatePlanBaseImage `json:"baseImages"`
+	Apps       []updatePlanApp       `json:"apps"`
+}
+
+// updatePlanBaseImage is the plan for the digest of a base image, in all work dirs that contain it.
+type updatePlanBaseImage struct {
+	Name     string                       `json:"name"`
+	WorkDirs []updatePlanBaseImageWorkDir `json:"workDirs"`
+}
+
+// updatePlanBaseImageWorkDir is the plan for the digest of a base image in a work dir.
+type updatePlanBaseImageWorkDir struct {
+	WorkDir string `json:"workDir"`
+	// Path of the config file, relative to the root
+	File string `json:"file"`
+	// Image and tag that the digest is for
+	Image     string         `json:"image"`
+	Current   string         `json:"current"`
+	Candidate string         `json:"candidate"`
+	Decision  updateDecision `json:"decision"`
+	Reason    string         `json:"reason,omitempty"`
+	// Versions of the current and candidate images, from their org.opencontainers.image.version label, if available
+	CurrentVersion   string `json:"currentVersion,omitempty"`
+	CandidateVersion string `json:"candidateVersion,omitempty"`
+}
+
+// updatePlanApp is the plan for the version of an app, in all work dirs that contain it.
+type updatePlanApp struct {
+	Name     string                 `json:"name"`
+	WorkDirs []updatePlanAppWorkDir `json:"workDirs"`
+}
+
+// updatePlanAppWorkDir is the plan for the version of an app in a work dir.
+type updatePlanAppWorkDir struct {
+	WorkDir string `json:"workDir"`
+	// Path of the app file, relative to the root
+	File      string         `json:"file"`
+	Current   string         `json:"current"`
+	Candidate string         `json:"candidate"`
+	Decision  updateDecision `json:"decision"`
+	Reason    string         `json:"reason,omitempty"`
+	// Checksums before the update
+	CurrentChecksums string `json:"currentChecksums,omitempty"`
+	// Checksums for the candidate version, set only if the app is updated and has checksums
+	Checksums string `json:"checksums,omitempty"`
+	// Links to the release notes of the candidate version, and to the changes since the current version, if the source has them
+	ReleaseURL string `json:"releaseUrl,omitempty"`
+	CompareURL string `json:"compareUrl,omitempty"`
+}
+
+// Summary returns a description of the update of the base image, with the current and candidate digests and versions.
+func (w updatePlanBaseImageWorkDir) Summary() string {
+	withVersion := func(digest string, version string) string {
+		if version == "" {
+			return "`" + digest + "`"
+		}
+		return "`" + digest + "` (" + version + ")"
+	}
+	return fmt.Sprintf("`%s` %s → %s", w.Image, withVersion(w.Current, w.CurrentVersion), withVersion(w.Candidate, w.CandidateVersion))
+}
+
+// Summary returns a description of the update of the app, with the current and candidate versions, links to the release, and whether the checksums changed.
+func (w updatePlanAppWorkDir) Summary() string {
+	res := w.Current + " → " + w.Candidate
+
+	links := make([]string, 0, 2)
+	if w.ReleaseURL != "" {
+		links = append(links, "[release notes]("+w.ReleaseURL+")")
+	}
+	if w.CompareURL != "" {
+		links = append(links, "[changes]("+w.CompareURL+")")
+	}
+	if len(links) > 0 {
+		res += " (" + strings.Join(links, ", ") + ")"
+	}
+
+	switch {
+	case w.Decision != updateDecisionUpdate || (w.Checksums == "" && w.CurrentChecksums == ""):
+		// Nothing to report for apps that aren't updated, or that don't have checksums
+	case w.Checksums != w.CurrentChecksums:
+		res += ", checksums changed"
+	default:
+		res += ", checksums unchanged"
+	}
+
+	return res
+}
+
+func (p updatePlan) String() string {
+	j, _ := json.MarshalIndent(p, "", "  ")
+	return string(j)
+}
+
+// HasUpdates returns true if the plan updates at least one value.
+func (p updatePlan) HasUpdates() bool {
+	return p.countDecisions(updateDecisionUpdate) > 0
+}
+
+// FailedChecks returns the number of items for which the check failed.
+func (p updatePlan) FailedChecks() int {
+	return p.countDecisions(updateDecisionFailed)
+}
+
+func (p updatePlan) countDecisions(decision updateDecision) int {
+	n := 0
+	for _, b := range p.BaseImages {
+		for _, w := range b.WorkDirs {
+			if w.Decision == decision {
+				n++
+			}
+		}
+	}
+	for _, a := range p.Apps {
+		for _, w := range a.WorkDirs {
+			if w.Decision == decision {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// loadUpdatePlan reads a plan from a JSON file.
+func loadUpdatePlan(fileName string) (*updatePlan, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	plan := &updatePlan{}
+	err = json.Unmarshal(data, plan)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file '%s': %w", fileName, err)
+	}
+	return plan, nil
+}
+
+// Apply writes the updates in the plan to the files.
+// Files are resolved against root, so a plan can be created in one checkout of the repository and applied in another.
+// Before making any change, it checks that the files still contain the current values recorded in the plan, so stale plans are not applied.
+func (p updatePlan) Apply(root string) error {
+	// Collect the edits for each file
+	type fileEdits struct {
+		path  string
+		edits []yamlEdit
+	}
+	files := make([]*fileEdits, 0)
+	filesMap := make(map[string]*fileEdits)
+	addEdit := func(file string, edit yamlEdit, current string) error {
+		if !filepath.IsLocal(file) {
+			return fmt.Errorf("path '%s' in the plan is not relative to the root", file)
+		}
+		f, ok := filesMap[file]
+		if !ok {
+			f = &fileEdits{path: filepath.Join(root, file)}
+			filesMap[file] = f
+			files = append(files, f)
+		}
+		edit.Current = &current
+		f.edits = append(f.edits, edit)
+		return nil
+	}
+
+	for _, b := range p.BaseImages {
+		for _, w := range b.WorkDirs {
+			if w.Decision != updateDecisionUpdate {
+				continue
+			}
+			if w.Candidate == "" || w.File == "" {
+				return fmt.Errorf("plan for base image '%s' in work dir '%s' does not have a candidate or a file", b.Name, w.WorkDir)
+			}
+			err := addEdit(w.File, yamlEdit{Path: []string{"baseImages", b.Name, "digest"}, Value: w.Candidate}, w.Current)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, a := range p.Apps {
+		for _, w := range a.WorkDirs {
+			if w.Decision != updateDecisionUpdate {
+				continue
+			}
+			if w.Candidate == "" || w.File == "" {
+				return fmt.Errorf("plan for app '%s' in work dir '%s' does not have a candidate or a file", a.Name, w.WorkDir)
+			}
+			err := addEdit(w.File, yamlEdit{Path: []string{"version"}, Value: w.Candidate}, w.Current)
+			if err != nil {
+				return err
+			}
+			if w.Checksums != "" {
+				err = addEdit(w.File, yamlEdit{Path: []string{"checksums"}, Value: w.Checksums}, w.CurrentChecksums)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Check all files first, so the plan is applied to all of them or to none
+	for _, f := range files {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return fmt.Errorf("error reading file '%s': %w", f.path, err)
+		}
+		_, err = editYaml(data, f.edits)
+		if err != nil {
+			return fmt.Errorf("cannot apply plan to file '%s', which may have changed since the plan was created: %w", f.path, err)
+		}
+	}
+
+	for _, f := range files {
+		fmt.Fprintf(os.Stderr, "Saving updated file: %s\n", f.path)
+		err := editYamlFile(f.path, f.edits)
+		if err != nil {
+			return fmt.Errorf("failed to save updated file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Markdown returns the summary of the updates, of the versions that are held back or rejected, and of the checks that failed, as markdown.
+// Work dirs with the same outcome for a base image or app are listed together.
+// Returns an empty string if there's nothing to report.
+func (p updatePlan) Markdown() string {
+	updated := make([]string, 0)
+	heldBack := make([]string, 0)
+	rejected := make([]string, 0)
+	failed := make([]string, 0)
+	for _, b := range p.BaseImages {
+		for _, g := range groupPlanWorkDirs(b.WorkDirs, func(w updatePlanBaseImageWorkDir) (string, updateDecision, string) {
+			switch w.Decision {
+			case updateDecisionFailed, updateDecisionRejected:
+				return w.WorkDir, w.Decision, singleLine(w.Reason)
+			default:
+				return w.WorkDir, w.Decision, w.Summary()
+			}
+		}) {
+			switch g.decision {
+			case updateDecisionUpdate:
+				updated = append(updated, fmt.Sprintf("Base image %s (%s): %s", b.Name, g.workDirs, g.text))
+			case updateDecisionRejected:
+				rejected = append(rejected, fmt.Sprintf("Base image %s (%s): %s", b.Name, g.workDirs, g.text))
+			case updateDecisionFailed:
+				failed = append(failed, fmt.Sprintf("Base image %s (%s): %s", b.Name, g.workDirs, g.text))
+			}
+		}
+	}
+	for _, a := range p.Apps {
+		for _, g := range groupPlanWorkDirs(a.WorkDirs, func(w updatePlanAppWorkDir) (string, updateDecision, string) {
+			switch w.Decision {
+			case updateDecisionFailed:
+				return w.WorkDir, w.Decision, singleLine(w.Reason)
+			case updateDecisionHeldBack:
+				return w.WorkDir, w.Decision, w.Summary() + " (" + w.Reason + ")"
+			default:
+				return w.WorkDir, w.Decision, w.Summary()
+			}
+		}) {
+			switch g.decision {
+			case updateDecisionUpdate:
+				updated = append(updated, fmt.Sprintf("App %s (%s): %s", a.Name, g.workDirs, g.text))
+			case updateDecisionHeldBack:
+				heldBack = append(heldBack, fmt.Sprintf("App %s (%s): %s", a.Name, g.workDirs, g.text))
+			case updateDecisionFailed:
+				failed = append(failed, fmt.Sprintf("App %s (%s): %s", a.Name, g.workDirs, g.text))
+			}
+		}
+	}
+
+	sections := make([]string, 0, 4)
+	addSection := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		var sb strings.Builder
+		if title != "" {
+			sb.WriteString(title + "\n")
+		}
+		for _, item := range items {
+			sb.WriteString("- " + item + "\n")
+		}
+		sections = append(sections, sb.String())
+	}
+	addSection("", updated)
+	addSection("Held back by the update policy:", heldBack)
+	addSection("Rejected because they don't meet the requirements of the config:", rejected)
+	addSection("Failed checks:", failed)
+
+	return strings.Join(sections, "\n")
+}
+
+// planWorkDirsGroup contains the work dirs that have the same outcome for an item in the plan.
+type planWorkDirsGroup struct {
+	// List of work dirs, comma-separated
+	workDirs string
+	decision updateDecision
+	// Description of the outcome
+	text string
+}
+
+// groupPlanWorkDirs groups the entries of an item in the plan by decision and description, keeping the order of the first entry in each group.
+func groupPlanWorkDirs[T any](entries []T, fields func(T) (workDir string, decision updateDecision, text string)) []planWorkDirsGroup {
+	res := make([]planWorkDirsGroup, 0, 1)
+	for _, e := range entries {
+		workDir, decision, text := fields(e)
+		found := false
+		for i := range res {
+			if res[i].decision == decision && res[i].text == text {
+				res[i].workDirs += ", " + workDir
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, planWorkDirsGroup{
+				workDirs: workDir,
+				decision: decision,
+				text:     text,
+			})
+		}
+	}
+	return res
+}
+
+// singleLine joins the lines of the text with spaces, so it fits in a list item.
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdatePlanApply(t *testing.T) {
+	newPlan := func(current string, file string) *updatePlan {
+		return &updatePlan{
+			// The plan was created in a different checkout
+			Root:     "/checkout/of/the/plan",
+			WorkDirs: []string{"el10"},
+			Apps: []updatePlanApp{{
+				Name: "tool",
+				WorkDirs: []updatePlanAppWorkDir{{
+					WorkDir:          "el10",
+					File:             file,
+					Current:          current,
+					Candidate:        "1.1.0",
+					Decision:         updateDecisionUpdate,
+					CurrentChecksums: "aaaa  tool.bin",
+					Checksums:        "bbbb  tool.bin",
+				}},
+			}},
+		}
+	}
+	const appFile = "name: tool\n# Pinned by the updater\nversion: 1.0.0\nchecksums: |-\n  aaaa  tool.bin\n"
+
+	tests := []struct {
+		name     string
+		plan     *updatePlan
+		expected string
+		err      string
+	}{
+		{
+			name:     "applied to the root",
+			plan:     newPlan("1.0.0", "el10/apps/tool/app.yaml"),
+			expected: "name: tool\n# Pinned by the updater\nversion: 1.1.0\nchecksums: |-\n  bbbb  tool.bin\n",
+		},
+		{
+			name:     "stale plan",
+			plan:     newPlan("0.9.0", "el10/apps/tool/app.yaml"),
+			expected: appFile,
+			err:      "may have changed since the plan was created",
+		},
+		{
+			name:     "file outside of the root",
+			plan:     newPlan("1.0.0", "../app.yaml"),
+			expected: appFile,
+			err:      "is not relative to the root",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFiles(t, root, map[string]string{
+				"el10/apps/tool/app.yaml": appFile,
+			})
+
+			err := tt.plan.Apply(root)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error containing %q, got %v", tt.err, err)
+				}
+			} else if err != nil {
+				t.Fatalf("failed to apply plan: %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(root, "el10/apps/tool/app.yaml"))
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("wrong file content:\n%s\nexpected:\n%s", data, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUpdatePlanMarkdown(t *testing.T) {
+	const (
+		digestA = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+		digestB = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+	)
+	plan := updatePlan{
+		Root:     ".",
+		WorkDirs: []string{"el9", "el10"},
+		BaseImages: []updatePlanBaseImage{
+			{
+				Name: "alma",
+				WorkDirs: []updatePlanBaseImageWorkDir{
+					{WorkDir: "el9", Image: "quay.io/almalinux/almalinux:9", Current: digestA, Candidate: digestB, Decision: updateDecisionUpdate, CurrentVersion: "9.6", CandidateVersion: "9.7"},
+					{WorkDir: "el10", Image: "quay.io/almalinux/almalinux:10", Current: digestA, Candidate: digestB, Decision: updateDecisionUpdate},
+				},
+			},
+			{
+				Name: "centos",
+				WorkDirs: []updatePlanBaseImageWorkDir{
+					{WorkDir: "el9", Image: "quay.io/centos/centos:stream9", Current: digestA, Candidate: digestA, Decision: updateDecisionUnchanged},
+					{WorkDir: "el10", Image: "quay.io/centos/centos:stream10", Current: digestA, Candidate: digestB, Decision: updateDecisionRejected, Reason: "image is missing platforms required by the config:\n  linux/arm64"},
+				},
+			},
+			{
+				Name: "fedora",
+				WorkDirs: []updatePlanBaseImageWorkDir{
+					{WorkDir: "el9", Image: "quay.io/fedora/fedora:43", Current: digestA, Decision: updateDecisionFailed, Reason: "failed to get digest: 500 Internal Server Error"},
+					{WorkDir: "el10", Image: "quay.io/fedora/fedora:43", Current: digestA, Decision: updateDecisionFailed, Reason: "failed to get digest: 500 Internal Server Error"},
+				},
+			},
+		},
+		Apps: []updatePlanApp{
+			{
+				Name: "k3s",
+				WorkDirs: []updatePlanAppWorkDir{
+					{
+						WorkDir: "el9", Current: "1.33.1", Candidate: "1.33.2", Decision: updateDecisionUpdate,
+						CurrentChecksums: "aaaa  k3s", Checksums: "bbbb  k3s",
+						ReleaseURL: "https://github.com/k3s-io/k3s/releases/tag/v1.33.2", CompareURL: "https://github.com/k3s-io/k3s/compare/v1.33.1...v1.33.2",
+					},
+					{
+						WorkDir: "el10", Current: "1.33.1", Candidate: "1.33.2", Decision: updateDecisionUpdate,
+						CurrentChecksums: "aaaa  k3s", Checksums: "bbbb  k3s",
+						ReleaseURL: "https://github.com/k3s-io/k3s/releases/tag/v1.33.2", CompareURL: "https://github.com/k3s-io/k3s/compare/v1.33.1...v1.33.2",
+					},
+				},
+			},
+			{
+				Name: "restic",
+				WorkDirs: []updatePlanAppWorkDir{
+					{WorkDir: "el9", Current: "0.18.0", Candidate: "0.18.1", Decision: updateDecisionUpdate, CurrentChecksums: "aaaa  restic", Checksums: "aaaa  restic"},
+					{WorkDir: "el10", Current: "0.17.3", Candidate: "0.18.1", Decision: updateDecisionUpdate},
+				},
+			},
+			{
+				Name: "tool",
+				WorkDirs: []updatePlanAppWorkDir{
+					{WorkDir: "el9", Current: "1.9.0", Candidate: "2.0.0", Decision: updateDecisionHeldBack, Reason: "major updates are not allowed"},
+					{WorkDir: "el10", Current: "1.9.0", Candidate: "1.10.0", Decision: updateDecisionUpdate},
+				},
+			},
+			{
+				Name: "gotop",
+				WorkDirs: []updatePlanAppWorkDir{
+					{WorkDir: "el10", Current: "4.2.0", Decision: updateDecisionFailed, Reason: "failed to get latest version:\ncontext deadline exceeded"},
+				},
+			},
+			{
+				Name: "cloudflared",
+				WorkDirs: []updatePlanAppWorkDir{
+					{WorkDir: "el9", Current: "2026.9.0", Candidate: "2026.9.0", Decision: updateDecisionUnchanged},
+					{WorkDir: "el10", Current: "2026.9.0", Candidate: "2026.10.0", Decision: updateDecisionIgnored},
+				},
+			},
+		},
+	}
+
+	checkGolden(t, filepath.Join("testdata", "update-plan", "plan.golden.md"), []byte(plan.Markdown()))
+
+	// Nothing to report
+	empty := updatePlan{
+		Apps: []updatePlanApp{{
+			Name:     "cloudflared",
+			WorkDirs: []updatePlanAppWorkDir{{WorkDir: "el9", Current: "2026.9.0", Candidate: "2026.9.0", Decision: updateDecisionUnchanged}},
+		}},
+	}
+	if res := empty.Markdown(); res != "" {
+		t.Errorf("expected an empty summary, got %q", res)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semVersion is a semantic version.
+type semVersion struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+	Build      string
+}
+
+// Semantic versions, optionally prefixed by "v"; the minor and patch numbers can be omitted
+var semVersionExp = regexp.MustCompile(`^v?([0-9]+)(?:\.([0-9]+))?(?:\.([0-9]+))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// parseSemVersion parses a semantic version, such as "1.36.3+k3s1".
+func parseSemVersion(s string) (semVersion, error) {
+	match := semVersionExp.FindStringSubmatch(s)
+	if match == nil {
+		return semVersion{}, fmt.Errorf("'%s' is not a semantic version", s)
+	}
+
+	var v semVersion
+	for i, dest := range []*uint64{&v.Major, &v.Minor, &v.Patch} {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(match[i+1], 10, 64)
+		if err != nil {
+			return semVersion{}, fmt.Errorf("'%s' is not a semantic version: %w", s, err)
+		}
+		*dest = n
+	}
+	v.Prerelease = match[4]
+	v.Build = match[5]
+	return v, nil
+}
+
+// Compare the version with another one, returning -1 if v is lower than o, 1 if it's higher, and 0 if they're equal.
+// Unlike the semantic versioning spec, build metadata is compared too when everything else is equal, as some projects use it for releases (such as "1.36.3+k3s2").
+func (v semVersion) Compare(o semVersion) int {
+	for _, c := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		switch {
+		case c[0] > c[1]:
+			return 1
+		case c[0] < c[1]:
+			return -1
+		}
+	}
+
+	// A version without pre-release is higher than one with it
+	switch {
+	case v.Prerelease == "" && o.Prerelease != "":
+		return 1
+	case v.Prerelease != "" && o.Prerelease == "":
+		return -1
+	}
+	if c := compareVersions(v.Prerelease, o.Prerelease); c != 0 {
+		return c
+	}
+
+	return compareVersions(v.Build, o.Build)
+}
+
+// versionConstraint is a constraint on semantic versions.
+// It's a list of alternatives, each with a list of comparisons that must all be satisfied.
+type versionConstraint [][]versionComparison
+
+type versionComparison struct {
+	Op      string
+	Version semVersion
+}
+
+var (
+	// Comparisons in a constraint, such as ">= 1.2", "~1.36", or "1.2.x"
+	versionComparisonExp = regexp.MustCompile(`(!=|>=|<=|=|>|<|~|\^)?\s*(v?[0-9xX*][0-9A-Za-z.*+-]*)`)
+	// Versions in a comparison, where numbers can be replaced by wildcards
+	partialVersionExp = regexp.MustCompile(`^v?([0-9]+|[xX*])(?:\.([0-9]+|[xX*]))?(?:\.([0-9]+|[xX*]))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+)
+
+// parseVersionConstraint parses a constraint such as "~1.36", "<2.0", ">=1.2, <1.5", or "1.x || 2.x".
+// Comparisons separated by commas or spaces must all be satisfied, while alternatives are separated by "||".
+// Supported operators are "=", "!=", ">", ">=", "<", "<=", "~" (same minor version, or same major version if only that is set), and "^" (same major version, or same minor version for 0.x); versions without an operator can use "x" or "*" as wildcards.
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New("constraint is empty")
+	}
+
+	var res versionConstraint
+	for _, alt := range strings.Split(s, "||") {
+		matches := versionComparisonExp.FindAllStringSubmatchIndex(alt, -1)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("invalid constraint '%s'", s)
+		}
+
+		comparisons := make([]versionComparison, 0, len(matches))
+		last := 0
+		for _, m := range matches {
+			// Only commas and spaces are allowed between comparisons
+			if strings.Trim(alt[last:m[0]], ", \t") != "" {
+				return nil, fmt.Errorf("invalid constraint '%s'", s)
+			}
+			last = m[1]
+
+			var op string
+			if m[2] >= 0 {
+				op = alt[m[2]:m[3]]
+			}
+			c, err := expandVersionComparison(op, alt[m[4]:m[5]])
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint '%s': %w", s, err)
+			}
+			comparisons = append(comparisons, c...)
+		}
+		if strings.Trim(alt[last:], ", \t") != "" {
+			return nil, fmt.Errorf("invalid constraint '%s'", s)
+		}
+
+		res = append(res, comparisons)
+	}
+
+	return res, nil
+}
+
+// expandVersionComparison converts a comparison, which can use partial versions and the "~" and "^" operators, into simple comparisons.
+func expandVersionComparison(op string, version string) ([]versionComparison, error) {
+	match := partialVersionExp.FindStringSubmatch(version)
+	if match == nil {
+		return nil, fmt.Errorf("'%s' is not a valid version", version)
+	}
+
+	// Count the parts that are set, until the first wildcard
+	var parts [3]uint64
+	set := 0
+	for i := range 3 {
+		p := match[i+1]
+		if p == "" || p == "x" || p == "X" || p == "*" {
+			break
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid version: %w", version, err)
+		}
+		parts[i] = n
+		set++
+	}
+
+	lower := semVersion{Major: parts[0], Minor: parts[1], Patch: parts[2]}
+	if set == 3 {
+		lower.Prerelease = match[4]
+	}
+	// Upper bound (exclusive) for versions that match the parts that are set
+	upperAt := func(i int) semVersion {
+		u := [3]uint64{}
+		copy(u[:i], parts[:i])
+		u[i-1]++
+		return semVersion{Major: u[0], Minor: u[1], Patch: u[2]}
+	}
+
+	switch op {
+	case "", "=":
+		if set == 0 {
+			return []versionComparison{}, nil
+		}
+		if set == 3 {
+			return []versionComparison{{"=", lower}}, nil
+		}
+		return []versionComparison{{">=", lower}, {"<", upperAt(set)}}, nil
+	case "!=":
+		if set < 3 {
+			return nil, fmt.Errorf("operator '!=' requires a full version, but got '%s'", version)
+		}
+		return []versionComparison{{"!=", lower}}, nil
+	case ">":
+		if set < 3 && set > 0 {
+			return []versionComparison{{">=", upperAt(set)}}, nil
+		}
+		return []versionComparison{{">", lower}}, nil
+	case "<=":
+		if set < 3 && set > 0 {
+			return []versionComparison{{"<", upperAt(set)}}, nil
+		}
+		return []versionComparison{{"<=", lower}}, nil
+	case ">=", "<":
+		return []versionComparison{{op, lower}}, nil
+	case "~":
+		if set == 0 {
+			return []versionComparison{}, nil
+		}
+		return []versionComparison{{">=", lower}, {"<", upperAt(min(set, 2))}}, nil
+	case "^":
+		if set == 0 {
+			return []versionComparison{}, nil
+		}
+		// The upper bound is given by the first part that is not zero
+		i := 1
+		for i < set && parts[i-1] == 0 {
+			i++
+		}
+		return []versionComparison{{">=", lower}, {"<", upperAt(i)}}, nil
+	default:
+		return nil, fmt.Errorf("invalid operator '%s'", op)
+	}
+}
+
+// Check returns true if the version satisfies the constraint.
+func (c versionConstraint) Check(v semVersion) bool {
+	for _, alt := range c {
+		ok := true
+		for _, comp := range alt {
+			// Build metadata is ignored when checking constraints
+			cmp := semVersion{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Prerelease: v.Prerelease}.Compare(comp.Version)
+			switch comp.Op {
+			case "=":
+				ok = cmp == 0
+			case "!=":
+				ok = cmp != 0
+			case ">":
+				ok = cmp > 0
+			case ">=":
+				ok = cmp >= 0
+			case "<":
+				// Pre-releases of the upper bound are excluded, so "<2.0.0" (and "~1.36") doesn't match "2.0.0-rc1"
+				ok = cmp < 0 && (v.Prerelease == "" || comp.Version.Prerelease != "" ||
+					v.Major != comp.Version.Major || v.Minor != comp.Version.Minor || v.Patch != comp.Version.Patch)
+			case "<=":
+				ok = cmp <= 0
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate the update policy's properties.
+func (p App_UpdatePolicy) Validate() error {
+	if p.Constraint != "" {
+		_, err := parseVersionConstraint(p.Constraint)
+		if err != nil {
+			return fmt.Errorf("property 'updatePolicy.constraint' is not valid: %w", err)
+		}
+	}
+	return nil
+}
+
+// CheckUpdate returns an error explaining why the update from the current version to the candidate is not allowed by the policy, or nil if it's allowed.
+func (p App_UpdatePolicy) CheckUpdate(current string, candidate string) error {
+	candidateSemver, semverErr := parseSemVersion(candidate)
+
+	// Pre-releases
+	if !p.AllowPrerelease && semverErr == nil && candidateSemver.Prerelease != "" {
+		return fmt.Errorf("version %s is a pre-release", candidate)
+	}
+
+	// Constraint
+	if p.Constraint != "" {
+		constraint, err := parseVersionConstraint(p.Constraint)
+		if err != nil {
+			return err
+		}
+		if semverErr != nil {
+			return fmt.Errorf("version %s can't be checked against the constraint '%s': %w", candidate, p.Constraint, semverErr)
+		}
+		if !constraint.Check(candidateSemver) {
+			return fmt.Errorf("version %s does not satisfy the constraint '%s'", candidate, p.Constraint)
+		}
+	}
+
+	// Downgrades
+	if !p.AllowDowngrade && current != "" {
+		var cmp int
+		currentSemver, err := parseSemVersion(current)
+		if err == nil && semverErr == nil {
+			cmp = candidateSemver.Compare(currentSemver)
+		} else {
+			cmp = compareVersions(candidate, current)
+		}
+		if cmp < 0 {
+			return fmt.Errorf("version %s is lower than the current version %s", candidate, current)
+		}
+	}
+
+	return nil
+}
+
+// isIgnoredVersion returns true if the version matches one of the patterns.
+// Patterns are versions, globs such as "1.84.*", or regular expressions wrapped in slashes such as "/^1\.9[0-9]\./".
+func isIgnoredVersion(patterns []string, version string) bool {
+	for _, p := range patterns {
+		switch {
+		case len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/"):
+			exp, err := regexp.Compile(p[1 : len(p)-1])
+			if err == nil && exp.MatchString(version) {
+				return true
+			}
+		case strings.ContainsAny(p, "*?["):
+			ok, err := path.Match(p, version)
+			if err == nil && ok {
+				return true
+			}
+		case p == version:
+			return true
+		}
+	}
+	return false
+}
+
+// validateIgnoredVersions returns an error if any of the patterns is not valid.
+func validateIgnoredVersions(patterns []string) error {
+	for _, p := range patterns {
+		switch {
+		case len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/"):
+			_, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return fmt.Errorf("ignored version '%s' is not a valid regular expression: %w", p, err)
+			}
+		case strings.ContainsAny(p, "*?["):
+			_, err := path.Match(p, "")
+			if err != nil {
+				return fmt.Errorf("ignored version '%s' is not a valid glob: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
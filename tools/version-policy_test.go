@@ -0,0 +1,286 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseSemVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected semVersion
+		err      bool
+	}{
+		{input: "1.2.3", expected: semVersion{Major: 1, Minor: 2, Patch: 3}},
+		{input: "v1.2.3", expected: semVersion{Major: 1, Minor: 2, Patch: 3}},
+		{input: "1.2", expected: semVersion{Major: 1, Minor: 2}},
+		{input: "1", expected: semVersion{Major: 1}},
+		{input: "1.36.3+k3s1", expected: semVersion{Major: 1, Minor: 36, Patch: 3, Build: "k3s1"}},
+		{input: "2.0.0-rc.1", expected: semVersion{Major: 2, Prerelease: "rc.1"}},
+		{input: "2.0.0-rc1+build.5", expected: semVersion{Major: 2, Prerelease: "rc1", Build: "build.5"}},
+		{input: "2026.8.2", expected: semVersion{Major: 2026, Minor: 8, Patch: 2}},
+		{input: "", err: true},
+		{input: "latest", err: true},
+		{input: "1.2.3.4", err: true},
+		{input: "1.2.x", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			v, err := parseSemVersion(tt.input)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if v != tt.expected {
+				t.Errorf("got %+v, expected %+v", v, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSemVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2.4", "1.2.3", 1},
+		{"1.10.0", "1.9.9", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"2.0.0-rc1", "2.0.0", -1},
+		{"2.0.0", "2.0.0-rc1", 1},
+		{"2.0.0-rc2", "2.0.0-rc1", 1},
+		{"2.0.0-rc10", "2.0.0-rc9", 1},
+		{"2.0.0-beta1", "2.0.0-alpha2", 1},
+		{"2.0.0-rc1", "1.9.9", 1},
+		{"1.36.3+k3s2", "1.36.3+k3s1", 1},
+		{"1.36.3+k3s1", "1.36.3", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			a, err := parseSemVersion(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := parseSemVersion(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res := a.Compare(b); res != tt.expected {
+				t.Errorf("Compare(%s, %s) = %d, expected %d", tt.a, tt.b, res, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVersionConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		// "~": same minor version, or same major version if only that is set
+		{"~1.36", "1.36.0", true},
+		{"~1.36", "1.36.99", true},
+		{"~1.36", "1.35.9", false},
+		{"~1.36", "1.37.0", false},
+		{"~1.36.2", "1.36.1", false},
+		{"~1.36.2", "1.36.2", true},
+		{"~1.36.2", "1.36.9", true},
+		{"~1.36.2", "1.37.0", false},
+		{"~1", "1.0.0", true},
+		{"~1", "1.99.0", true},
+		{"~1", "2.0.0", false},
+		{"~1.36", "1.36.3+k3s1", true},
+
+		// "^": same major version, or same minor version for 0.x
+		{"^1.2", "1.2.0", true},
+		{"^1.2", "1.1.9", false},
+		{"^1.2", "1.99.0", true},
+		{"^1.2", "2.0.0", false},
+		{"^1.2.3", "1.2.2", false},
+		{"^1.2.3", "1.2.3", true},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0", "0.99.0", true},
+		{"^0", "1.0.0", false},
+
+		// Pre-releases
+		{"~1.36", "1.37.0-rc1", false},
+		{"^1.2", "2.0.0-rc1", false},
+		{"<2.0", "2.0.0-rc1", false},
+		{"<2.0.0-rc2", "2.0.0-rc1", true},
+		{">=2.0.0-rc1", "2.0.0-rc2", true},
+		{">=2.0.0-rc1", "2.0.0-beta1", false},
+		{"~1.36", "1.36.1-rc1", true},
+		{">=2.0", "2.0.0-rc1", false},
+
+		// Wildcards and partial versions
+		{"1.x", "1.5.0", true},
+		{"1.x", "2.0.0", false},
+		{"1.2.*", "1.2.9", true},
+		{"1.2.*", "1.3.0", false},
+		{"1.2", "1.2.5", true},
+		{"*", "99.0.0", true},
+		{"1.2.3", "1.2.3", true},
+		{"=1.2.3", "1.2.4", false},
+
+		// Comparisons
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{">1.2.3", "1.2.4", true},
+		{"<=1.2", "1.2.9", true},
+		{"<=1.2", "1.3.0", false},
+		{"!=1.2.3", "1.2.3", false},
+		{"!=1.2.3", "1.2.4", true},
+		{">=1.2, <1.5", "1.4.9", true},
+		{">=1.2, <1.5", "1.5.0", false},
+		{">=1.2 <1.5", "1.1.0", false},
+		{">= 1.2,<1.5", "1.2.0", true},
+		{"v1.x", "1.0.0", true},
+
+		// Alternatives
+		{"1.x || 3.x", "1.5.0", true},
+		{"1.x || 3.x", "2.5.0", false},
+		{"1.x || 3.x", "3.0.0", true},
+		{"~1.36 || >=2.1, <2.3", "2.2.0", true},
+		{"~1.36 || >=2.1, <2.3", "2.0.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+"_"+tt.version, func(t *testing.T) {
+			c, err := parseVersionConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("failed to parse constraint: %v", err)
+			}
+			v, err := parseSemVersion(tt.version)
+			if err != nil {
+				t.Fatalf("failed to parse version: %v", err)
+			}
+			if res := c.Check(v); res != tt.expected {
+				t.Errorf("constraint '%s' with version %s = %v, expected %v", tt.constraint, tt.version, res, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseVersionConstraintInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"latest",
+		">=1.2 and <2",
+		"~>1.2",
+		"!=1.2",
+		"1.2 ||",
+		"1.2.3.4",
+		">=1.2; <2",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			_, err := parseVersionConstraint(tt)
+			if err == nil {
+				t.Errorf("expected an error for constraint '%s'", tt)
+			}
+		})
+	}
+}
+
+func TestUpdatePolicyCheckUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    App_UpdatePolicy
+		current   string
+		candidate string
+		allowed   bool
+	}{
+		{name: "update", current: "1.2.3", candidate: "1.2.4", allowed: true},
+		{name: "same version", current: "1.2.3", candidate: "1.2.3", allowed: true},
+		{name: "downgrade", current: "1.2.3", candidate: "1.2.2", allowed: false},
+		{name: "allowed downgrade", policy: App_UpdatePolicy{AllowDowngrade: true}, current: "1.2.3", candidate: "1.2.2", allowed: true},
+		{name: "non-semver downgrade", current: "2026.10.1", candidate: "2026.9.30", allowed: false},
+		{name: "pre-release", current: "1.2.3", candidate: "1.3.0-rc1", allowed: false},
+		{name: "allowed pre-release", policy: App_UpdatePolicy{AllowPrerelease: true}, current: "1.2.3", candidate: "1.3.0-rc1", allowed: true},
+		{name: "constraint satisfied", policy: App_UpdatePolicy{Constraint: "~1.36"}, current: "1.36.1+k3s1", candidate: "1.36.3+k3s1", allowed: true},
+		{name: "constraint not satisfied", policy: App_UpdatePolicy{Constraint: "~1.36"}, current: "1.36.1+k3s1", candidate: "1.37.0+k3s1", allowed: false},
+		{name: "pre-release of the next version", policy: App_UpdatePolicy{Constraint: "~1.36", AllowPrerelease: true}, current: "1.36.1", candidate: "1.37.0-rc1", allowed: false},
+		{name: "not a semantic version with a constraint", policy: App_UpdatePolicy{Constraint: "~1.36"}, current: "1.36.1", candidate: "latest", allowed: false},
+		{name: "not a semantic version without a constraint", current: "r100", candidate: "r101", allowed: true},
+		{name: "no current version", current: "", candidate: "1.0.0", allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.CheckUpdate(tt.current, tt.candidate)
+			if tt.allowed && err != nil {
+				t.Errorf("expected update to be allowed, got %v", err)
+			} else if !tt.allowed && err == nil {
+				t.Error("expected update not to be allowed")
+			}
+		})
+	}
+}
+
+func TestIsIgnoredVersion(t *testing.T) {
+	patterns := []string{"1.2.3", "1.84.*", `/^1\.9[0-9]\./`, "2.0.?"}
+
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{"1.2.3", true},
+		{"1.2.4", false},
+		{"1.84.0", true},
+		{"1.84.10", true},
+		{"1.85.0", false},
+		{"1.90.1", true},
+		{"1.99.0", true},
+		{"1.9.0", false},
+		{"2.0.1", true},
+		{"2.0.10", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if res := isIgnoredVersion(patterns, tt.version); res != tt.expected {
+				t.Errorf("isIgnoredVersion(%s) = %v, expected %v", tt.version, res, tt.expected)
+			}
+		})
+	}
+
+	if isIgnoredVersion(nil, "1.2.3") {
+		t.Error("no version must be ignored without patterns")
+	}
+}
+
+func TestValidateIgnoredVersions(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		valid    bool
+	}{
+		{[]string{"1.2.3", "1.84.*", `/^1\.9[0-9]\./`}, true},
+		{[]string{`/^1\.9[0-9\./`}, false},
+		{[]string{"1.[2"}, false},
+		// A single slash is a version, not a regular expression
+		{[]string{"/"}, true},
+	}
+
+	for _, tt := range tests {
+		err := validateIgnoredVersions(tt.patterns)
+		if tt.valid && err != nil {
+			t.Errorf("patterns %v: unexpected error %v", tt.patterns, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("patterns %v: expected an error", tt.patterns)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types/ref"
+	"github.com/ulikunitz/xz"
+)
+
+// Types of sources for the latest version of apps
+const (
+	versionSourceGitHubRelease = "github-release"
+	versionSourcePyPI          = "pypi"
+	versionSourceRPMRepo       = "rpm-repo"
+	versionSourceContainerTag  = "container-tag"
+)
+
+// Tags of container images that are considered versions when the source doesn't set "match"
+var defaultContainerTagExp = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*$`)
+
+// Validate the source's properties.
+func (s App_Source) Validate() error {
+	required := map[string]string{}
+	switch s.Type {
+	case versionSourceGitHubRelease:
+		required["repo"] = s.Repo
+	case versionSourcePyPI:
+		required["package"] = s.Package
+	case versionSourceRPMRepo:
+		required["url"] = s.URL
+		required["package"] = s.Package
+	case versionSourceContainerTag:
+		required["image"] = s.Image
+	case "":
+		return errors.New("property 'source.type' is required")
+	default:
+		return fmt.Errorf("invalid source type '%s': must be one of '%s', '%s', '%s', '%s'", s.Type, versionSourceGitHubRelease, versionSourcePyPI, versionSourceRPMRepo, versionSourceContainerTag)
+	}
+
+	for prop, val := range required {
+		if val == "" {
+			return fmt.Errorf("property 'source.%s' is required for sources of type '%s'", prop, s.Type)
+		}
+	}
+	if s.Type == versionSourceGitHubRelease && strings.Count(s.Repo, "/") != 1 {
+		return fmt.Errorf("property 'source.repo' must be in the format 'owner/name', but got '%s'", s.Repo)
+	}
+	if s.Match != "" {
+		_, err := regexp.Compile(s.Match)
+		if err != nil {
+			return fmt.Errorf("property 'source.match' is not a valid regular expression: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Base URL of the GitHub website, used for links to releases
+const gitHubURL = "https://github.com"
+
+// ReleaseLinks returns the URL of the release notes for the version, and the URL of the changes since the previous version.
+// The URLs are empty if the source doesn't have release pages, such as if it's not a GitHub repository; the compare URL is also empty if there's no previous version.
+func (s App_Source) ReleaseLinks(previous string, version string) (releaseURL string, compareURL string) {
+	if s.Type != versionSourceGitHubRelease || s.Repo == "" || version == "" {
+		return "", ""
+	}
+
+	// Versions don't have the prefix, so it's added back to get the tags
+	repoURL := gitHubURL + "/" + s.Repo
+	releaseURL = repoURL + "/releases/tag/" + url.PathEscape(s.StripPrefix+version)
+	if previous != "" && previous != version {
+		compareURL = repoURL + "/compare/" + url.PathEscape(s.StripPrefix+previous) + "..." + url.PathEscape(s.StripPrefix+version)
+	}
+	return releaseURL, compareURL
+}
+
+// versionSources fetches the latest versions of apps from their sources.
+type versionSources struct {
+	HTTPClient *http.Client
+	RegClient  *regclient.RegClient
+
+	// Base URL of the GitHub API
+	GitHubAPIURL string
+	// Token used to authenticate with the GitHub API, optional
+	GitHubToken string
+	// Base URL of PyPI
+	PyPIURL string
+}
+
+// AppVersion returns the latest version of the app, from its source or by running its update version script.
+func (v *versionSources) AppVersion(ctx context.Context, app *App) (string, error) {
+	if app.Source == nil {
+		out := &strings.Builder{}
+		err := runShellScript(ctx, app.Cmds.UpdateVersion, out, true)
+		if err != nil {
+			return "", fmt.Errorf("failed to run update version script: %w", err)
+		}
+		return strings.TrimSpace(out.String()), nil
+	}
+
+	return v.LatestVersion(ctx, app.Source)
+}
+
+// appVersionKey returns a key that identifies where the latest version of the app comes from.
+// Apps with the same key have the same latest version, so it's looked up only once.
+func appVersionKey(app *App) string {
+	if app.Source == nil {
+		return "script:" + app.Cmds.UpdateVersion
+	}
+	j, _ := json.Marshal(app.Source)
+	return "source:" + string(j)
+}
+
+// LatestVersion returns the latest version published in the source.
+func (v *versionSources) LatestVersion(ctx context.Context, source *App_Source) (string, error) {
+	err := source.Validate()
+	if err != nil {
+		return "", permanentError{err}
+	}
+
+	var version string
+	switch source.Type {
+	case versionSourceGitHubRelease:
+		version, err = v.gitHubRelease(ctx, source)
+	case versionSourcePyPI:
+		version, err = v.pyPIVersion(ctx, source)
+	case versionSourceRPMRepo:
+		version, err = v.rpmRepoVersion(ctx, source)
+	case versionSourceContainerTag:
+		version, err = v.containerTagVersion(ctx, source)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get latest version from source '%s': %w", source.Type, err)
+	}
+
+	version = strings.TrimPrefix(version, source.StripPrefix)
+	if version == "" {
+		return "", fmt.Errorf("source '%s' returned an empty version", source.Type)
+	}
+
+	return version, nil
+}
+
+func (v *versionSources) gitHubRelease(ctx context.Context, source *App_Source) (string, error) {
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	err := v.getJSON(ctx, strings.TrimSuffix(v.GitHubAPIURL, "/")+"/repos/"+source.Repo+"/releases/latest", &release)
+	if err != nil {
+		return "", err
+	}
+
+	return release.TagName, nil
+}
+
+func (v *versionSources) pyPIVersion(ctx context.Context, source *App_Source) (string, error) {
+	var pkg struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	err := v.getJSON(ctx, strings.TrimSuffix(v.PyPIURL, "/")+"/pypi/"+url.PathEscape(source.Package)+"/json", &pkg)
+	if err != nil {
+		return "", err
+	}
+
+	return pkg.Info.Version, nil
+}
+
+func (v *versionSources) rpmRepoVersion(ctx context.Context, source *App_Source) (string, error) {
+	baseURL := strings.TrimSuffix(source.URL, "/")
+
+	// The index of the repository contains the location of the list of packages
+	var repomd struct {
+		Data []struct {
+			Type     string `xml:"type,attr"`
+			Location struct {
+				Href string `xml:"href,attr"`
+			} `xml:"location"`
+		} `xml:"data"`
+	}
+	err := v.get(ctx, baseURL+"/repodata/repomd.xml", 30*time.Second, func(body io.Reader) error {
+		return xml.NewDecoder(body).Decode(&repomd)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read repository index: %w", err)
+	}
+	var primaryHref string
+	for _, d := range repomd.Data {
+		if d.Type == "primary" {
+			primaryHref = d.Location.Href
+			break
+		}
+	}
+	if primaryHref == "" {
+		return "", errors.New("repository index does not contain the location of the list of packages")
+	}
+
+	// Find the highest version of the package
+	var latest string
+	err = v.get(ctx, baseURL+"/"+strings.TrimPrefix(primaryHref, "/"), 2*time.Minute, func(body io.Reader) error {
+		var r io.Reader
+		switch path.Ext(primaryHref) {
+		case ".gz":
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				return err
+			}
+			defer gz.Close()
+			r = gz
+		case ".xz":
+			xzr, err := xz.NewReader(body)
+			if err != nil {
+				return err
+			}
+			r = xzr
+		case ".zst":
+			zr, err := zstd.NewReader(body)
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+			r = zr
+		case ".xml":
+			r = body
+		default:
+			return fmt.Errorf("unsupported compression for file '%s'", primaryHref)
+		}
+
+		// The list can be large, so packages are decoded one at a time
+		dec := xml.NewDecoder(r)
+		for {
+			tok, err := dec.Token()
+			if errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			start, ok := tok.(xml.StartElement)
+			if !ok || start.Name.Local != "package" {
+				continue
+			}
+
+			var pkg struct {
+				Name    string `xml:"name"`
+				Version struct {
+					Ver string `xml:"ver,attr"`
+				} `xml:"version"`
+			}
+			err = dec.DecodeElement(&pkg, &start)
+			if err != nil {
+				return err
+			}
+			if pkg.Name == source.Package && (latest == "" || compareVersions(pkg.Version.Ver, latest) > 0) {
+				latest = pkg.Version.Ver
+			}
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read list of packages: %w", err)
+	}
+	if latest == "" {
+		return "", fmt.Errorf("package '%s' not found in the repository", source.Package)
+	}
+
+	return latest, nil
+}
+
+func (v *versionSources) containerTagVersion(parentCtx context.Context, source *App_Source) (string, error) {
+	r, err := ref.New(source.Image)
+	if err != nil {
+		return "", permanentError{fmt.Errorf("failed to parse image reference '%s': %w", source.Image, err)}
+	}
+
+	ctx, cancel := contextWithDefaultTimeout(parentCtx, 30*time.Second)
+	defer cancel()
+	tl, err := v.RegClient.TagList(ctx, r)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags of image '%s': %w", source.Image, err)
+	}
+	tags, err := tl.GetTags()
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags of image '%s': %w", source.Image, err)
+	}
+
+	match := defaultContainerTagExp
+	if source.Match != "" {
+		match = regexp.MustCompile(source.Match)
+	}
+	var latest string
+	for _, tag := range tags {
+		if match.MatchString(tag) && (latest == "" || compareVersions(tag, latest) > 0) {
+			latest = tag
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no tag of image '%s' matches '%s'", source.Image, match)
+	}
+
+	return latest, nil
+}
+
+// getJSON sends a GET request to the URL and decodes the JSON response into dest.
+func (v *versionSources) getJSON(ctx context.Context, reqURL string, dest any) error {
+	return v.get(ctx, reqURL, 30*time.Second, func(body io.Reader) error {
+		err := json.NewDecoder(body).Decode(dest)
+		if err != nil {
+			return fmt.Errorf("invalid response from '%s': %w", reqURL, err)
+		}
+		return nil
+	})
+}
+
+// get sends a GET request to the URL and invokes read with the body of a successful response.
+// The timeout includes the time spent reading the body, and is used only if the context doesn't have a deadline.
+// Client errors, other than timeouts and rate limiting, are returned as permanent errors.
+func (v *versionSources) get(parentCtx context.Context, reqURL string, timeout time.Duration, read func(body io.Reader) error) error {
+	ctx, cancel := contextWithDefaultTimeout(parentCtx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if strings.HasPrefix(reqURL, strings.TrimSuffix(v.GitHubAPIURL, "/")+"/") {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if v.GitHubToken != "" {
+			req.Header.Set("Authorization", "Bearer "+v.GitHubToken)
+		}
+	}
+
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to '%s' failed: %w", reqURL, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		// Include the beginning of the response in the error, which usually explains the failure
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		err = fmt.Errorf("request to '%s' failed with status %d: %s", reqURL, res.StatusCode, strings.TrimSpace(string(body)))
+		if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusRequestTimeout && res.StatusCode != http.StatusTooManyRequests {
+			return permanentError{err}
+		}
+		return err
+	}
+
+	return read(res.Body)
+}
+
+// Segments of versions that are compared with each other
+var versionSegmentExp = regexp.MustCompile(`[0-9]+|[a-zA-Z]+`)
+
+// compareVersions compares two versions, returning -1 if a is lower than b, 1 if it's higher, and 0 if they're equal.
+// Versions are split into segments of digits or letters, which are compared in order: digits are compared as numbers, and are considered higher than letters.
+// This is similar to how RPM compares versions. A "v" prefix, as in "v1.2.3", is ignored.
+func compareVersions(a, b string) int {
+	segA := versionSegmentExp.FindAllString(trimVersionPrefix(a), -1)
+	segB := versionSegmentExp.FindAllString(trimVersionPrefix(b), -1)
+	for i := 0; i < len(segA) && i < len(segB); i++ {
+		x, y := segA[i], segB[i]
+		xNum, yNum := x[0] >= '0' && x[0] <= '9', y[0] >= '0' && y[0] <= '9'
+		switch {
+		case xNum && !yNum:
+			return 1
+		case !xNum && yNum:
+			return -1
+		case xNum:
+			// Compare numbers by their length first, so there's no limit to their size
+			x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			if len(x) != len(y) {
+				if len(x) > len(y) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+
+	// If all segments are equal, the version with more segments is higher
+	switch {
+	case len(segA) > len(segB):
+		return 1
+	case len(segA) < len(segB):
+		return -1
+	default:
+		return 0
+	}
+}
+
+// trimVersionPrefix removes the "v" prefix from versions such as "v1.2.3".
+func trimVersionPrefix(version string) string {
+	if len(version) > 1 && (version[0] == 'v' || version[0] == 'V') && version[1] >= '0' && version[1] <= '9' {
+		return version[1:]
+	}
+	return version
+}
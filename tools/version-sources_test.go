@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/config"
+	"github.com/ulikunitz/xz"
+)
+
+// newStubServer returns a server that responds to the paths in routes with their content, and with 404 to all other paths.
+func newStubServer(t *testing.T, routes map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".json") || strings.Contains(r.URL.Path, "/releases/") || strings.HasSuffix(r.URL.Path, "/tags/list") {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLatestVersionGitHubRelease(t *testing.T) {
+	var gotAuth, gotAccept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/restic/restic/releases/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		gotAccept = r.Header.Get("Accept")
+		_, _ = w.Write([]byte(`{"tag_name":"v0.19.1","name":"restic 0.19.1"}`))
+	}))
+	defer srv.Close()
+
+	sources := &versionSources{
+		HTTPClient:   srv.Client(),
+		GitHubAPIURL: srv.URL + "/",
+		GitHubToken:  "secret",
+	}
+
+	version, err := sources.LatestVersion(context.Background(), &App_Source{
+		Type:        versionSourceGitHubRelease,
+		Repo:        "restic/restic",
+		StripPrefix: "v",
+	})
+	if err != nil {
+		t.Fatalf("failed to get version: %v", err)
+	}
+	if version != "0.19.1" {
+		t.Errorf("wrong version '%s'", version)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("wrong Authorization header '%s'", gotAuth)
+	}
+	if gotAccept != "application/vnd.github+json" {
+		t.Errorf("wrong Accept header '%s'", gotAccept)
+	}
+
+	// Repositories that don't exist are permanent errors, which are not retried
+	_, err = sources.LatestVersion(context.Background(), &App_Source{
+		Type: versionSourceGitHubRelease,
+		Repo: "restic/missing",
+	})
+	var permErr permanentError
+	if !errors.As(err, &permErr) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestLatestVersionServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	sources := &versionSources{
+		HTTPClient:   srv.Client(),
+		GitHubAPIURL: srv.URL,
+	}
+	_, err := sources.LatestVersion(context.Background(), &App_Source{
+		Type: versionSourceGitHubRelease,
+		Repo: "restic/restic",
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	var permErr permanentError
+	if errors.As(err, &permErr) {
+		t.Errorf("server errors must not be permanent: %v", err)
+	}
+	if !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("error does not include the response: %v", err)
+	}
+}
+
+func TestLatestVersionPyPI(t *testing.T) {
+	srv := newStubServer(t, map[string][]byte{
+		"/pypi/ansible-core/json": []byte(`{"info":{"name":"ansible-core","version":"2.19.3"},"releases":{}}`),
+	})
+
+	sources := &versionSources{
+		HTTPClient: srv.Client(),
+		PyPIURL:    srv.URL,
+	}
+	version, err := sources.LatestVersion(context.Background(), &App_Source{
+		Type:    versionSourcePyPI,
+		Package: "ansible-core",
+	})
+	if err != nil {
+		t.Fatalf("failed to get version: %v", err)
+	}
+	if version != "2.19.3" {
+		t.Errorf("wrong version '%s'", version)
+	}
+}
+
+const testPrimaryXML = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="4">
+<package type="rpm"><name>zfs</name><arch>x86_64</arch><version epoch="0" ver="2.3.4" rel="1.el10"/></package>
+<package type="rpm"><name>zfs</name><arch>x86_64</arch><version epoch="0" ver="2.3.10" rel="1.el10"/></package>
+<package type="rpm"><name>zfs-dkms</name><arch>noarch</arch><version epoch="0" ver="2.4.0" rel="1.el10"/></package>
+<package type="rpm"><name>zfs</name><arch>x86_64</arch><version epoch="0" ver="2.3.9" rel="1.el10"/></package>
+</metadata>
+`
+
+func TestLatestVersionRPMRepo(t *testing.T) {
+	compress := map[string]func(t *testing.T, data []byte) []byte{
+		".xml": func(t *testing.T, data []byte) []byte {
+			return data
+		},
+		".gz": func(t *testing.T, data []byte) []byte {
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			_, _ = w.Write(data)
+			if err := w.Close(); err != nil {
+				t.Fatalf("failed to compress: %v", err)
+			}
+			return buf.Bytes()
+		},
+		".xz": func(t *testing.T, data []byte) []byte {
+			var buf bytes.Buffer
+			w, err := xz.NewWriter(&buf)
+			if err != nil {
+				t.Fatalf("failed to create writer: %v", err)
+			}
+			_, _ = w.Write(data)
+			if err := w.Close(); err != nil {
+				t.Fatalf("failed to compress: %v", err)
+			}
+			return buf.Bytes()
+		},
+		".zst": func(t *testing.T, data []byte) []byte {
+			var buf bytes.Buffer
+			w, err := zstd.NewWriter(&buf)
+			if err != nil {
+				t.Fatalf("failed to create writer: %v", err)
+			}
+			_, _ = w.Write(data)
+			if err := w.Close(); err != nil {
+				t.Fatalf("failed to compress: %v", err)
+			}
+			return buf.Bytes()
+		},
+	}
+
+	for ext, fn := range compress {
+		t.Run(ext, func(t *testing.T) {
+			// Such as "primary.xml.gz", or "primary.xml" if not compressed
+			primary := "repodata/0123456789abcdef-primary.xml" + strings.TrimPrefix(ext, ".xml")
+			srv := newStubServer(t, map[string][]byte{
+				"/repo/repodata/repomd.xml": []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo">
+  <data type="filelists"><location href="repodata/filelists.xml.gz"/></data>
+  <data type="primary"><location href="` + primary + `"/></data>
+</repomd>
+`),
+				"/repo/" + primary: fn(t, []byte(testPrimaryXML)),
+			})
+
+			sources := &versionSources{HTTPClient: srv.Client()}
+			version, err := sources.LatestVersion(context.Background(), &App_Source{
+				Type:    versionSourceRPMRepo,
+				URL:     srv.URL + "/repo/",
+				Package: "zfs",
+			})
+			if err != nil {
+				t.Fatalf("failed to get version: %v", err)
+			}
+			if version != "2.3.10" {
+				t.Errorf("wrong version '%s'", version)
+			}
+
+			_, err = sources.LatestVersion(context.Background(), &App_Source{
+				Type:    versionSourceRPMRepo,
+				URL:     srv.URL + "/repo",
+				Package: "missing",
+			})
+			if err == nil || !strings.Contains(err.Error(), "package 'missing' not found") {
+				t.Errorf("expected an error for a missing package, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLatestVersionContainerTag(t *testing.T) {
+	srv := newStubServer(t, map[string][]byte{
+		"/v2/":                      []byte(`{}`),
+		"/v2/rancher/k3s/tags/list": []byte(`{"name":"rancher/k3s","tags":["latest","v1.9.0","v1.36.3-k3s1","v1.36.3","v1.10.2","1.5","v1.36.3-rc1"]}`),
+	})
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	sources := &versionSources{
+		RegClient: regclient.New(regclient.WithConfigHost(config.Host{
+			Name: host,
+			TLS:  config.TLSDisabled,
+		})),
+	}
+
+	tests := []struct {
+		name     string
+		source   App_Source
+		expected string
+	}{
+		{
+			name:     "default match",
+			source:   App_Source{Type: versionSourceContainerTag, Image: host + "/rancher/k3s", StripPrefix: "v"},
+			expected: "1.36.3",
+		},
+		{
+			name:     "custom match",
+			source:   App_Source{Type: versionSourceContainerTag, Image: host + "/rancher/k3s", Match: `^v[0-9.]+-k3s[0-9]+$`},
+			expected: "v1.36.3-k3s1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := sources.LatestVersion(context.Background(), &tt.source)
+			if err != nil {
+				t.Fatalf("failed to get version: %v", err)
+			}
+			if version != tt.expected {
+				t.Errorf("wrong version '%s', expected '%s'", version, tt.expected)
+			}
+		})
+	}
+
+	_, err := sources.LatestVersion(context.Background(), &App_Source{Type: versionSourceContainerTag, Image: host + "/rancher/k3s", Match: `^nope$`})
+	if err == nil {
+		t.Error("expected an error when no tag matches")
+	}
+
+	_, err = sources.LatestVersion(context.Background(), &App_Source{Type: versionSourceContainerTag, Image: "Not a valid image!"})
+	var permErr permanentError
+	if !errors.As(err, &permErr) || !strings.Contains(err.Error(), "failed to parse image reference 'Not a valid image!'") {
+		t.Errorf("expected a permanent error for the invalid image reference, got %v", err)
+	}
+}
+
+func TestLatestVersionInvalidSource(t *testing.T) {
+	sources := &versionSources{HTTPClient: &http.Client{Transport: failingTransport{}}}
+	_, err := sources.LatestVersion(context.Background(), &App_Source{Type: versionSourcePyPI})
+	var permErr permanentError
+	if !errors.As(err, &permErr) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
+
+// failingTransport fails all requests, to make sure that no request is sent
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.1", "1.0.0", 1},
+		{"1.0.0", "1.0.1", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.9.0", "1.10.0", -1},
+		{"2.0", "1.99.99", 1},
+		{"1.0", "1.0.0", -1},
+		{"1.0.0", "1.0", 1},
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.36.3", "1.5", 1},
+		{"version", "1", -1},
+		{"1.02", "1.2", 0},
+		{"1.36.3+k3s1", "1.36.3", 1},
+		{"1.36.3+k3s2", "1.36.3+k3s1", 1},
+		{"2026.8.2", "2026.10.0", -1},
+		// Numbers are higher than letters
+		{"1.0.1", "1.0.rc1", 1},
+		{"1.0.rc1", "1.0.1", -1},
+		{"1.0a", "1.0b", -1},
+		// No limit to the size of numbers
+		{"1.123456789012345678901234567890", "1.123456789012345678901234567889", 1},
+		{"", "", 0},
+		{"", "1", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			res := compareVersions(tt.a, tt.b)
+			if res != tt.expected {
+				t.Errorf("compareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, res, tt.expected)
+			}
+		})
+	}
+}
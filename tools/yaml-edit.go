@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlEdit is a change to the value of a string in a YAML file.
+type yamlEdit struct {
+	// Keys of the mappings that lead to the value, for example: "baseImages", "alma-linux-10", "digest"
+	Path []string
+	// New value
+	Value string
+	// If not nil, the edit fails if the current value is different; missing values are considered empty
+	Current *string
+}
+
+// editYamlFile applies the edits to the YAML file.
+// Only the text of the edited values is changed, while everything else in the file, including comments and formatting, is left untouched.
+func editYamlFile(fileName string, edits []yamlEdit) error {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	data, err = editYaml(data, edits)
+	if err != nil {
+		return fmt.Errorf("error editing file '%s': %w", fileName, err)
+	}
+
+	err = os.WriteFile(fileName, data, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("error writing to file: %w", err)
+	}
+
+	return nil
+}
+
+// editYaml applies the edits to the YAML document and returns the updated document.
+// If a key doesn't exist, it's added at the end of its mapping, which must exist.
+func editYaml(data []byte, edits []yamlEdit) ([]byte, error) {
+	for _, edit := range edits {
+		if len(edit.Path) == 0 {
+			return nil, errors.New("path of the value to edit is empty")
+		}
+
+		// Parse the document again after each edit, so positions are up to date
+		var doc yaml.Node
+		err := yaml.Unmarshal(data, &doc)
+		if err != nil {
+			return nil, err
+		}
+		if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+			return nil, errors.New("document is empty")
+		}
+
+		// Find the mapping that contains the key
+		parent := doc.Content[0]
+		for i, key := range edit.Path[:len(edit.Path)-1] {
+			_, value := yamlMappingEntry(parent, key)
+			if value == nil {
+				return nil, fmt.Errorf("property '%s' not found", strings.Join(edit.Path[:i+1], "."))
+			}
+			parent = value
+		}
+		if parent.Kind != yaml.MappingNode || parent.Style&yaml.FlowStyle != 0 {
+			return nil, fmt.Errorf("property '%s' is not a block mapping", strings.Join(edit.Path[:len(edit.Path)-1], "."))
+		}
+
+		keyName := edit.Path[len(edit.Path)-1]
+		key, value := yamlMappingEntry(parent, keyName)
+		if value != nil && value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("property '%s' is not a scalar", strings.Join(edit.Path, "."))
+		}
+		if edit.Current != nil {
+			var current string
+			if value != nil {
+				current = value.Value
+			}
+			if current != *edit.Current {
+				return nil, fmt.Errorf("property '%s' has value '%s', but '%s' was expected", strings.Join(edit.Path, "."), current, *edit.Current)
+			}
+		}
+
+		if value != nil {
+			data, err = replaceYamlScalar(data, key, value, edit.Value)
+		} else {
+			data, err = appendYamlMappingEntry(data, parent, keyName, edit.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to set property '%s': %w", strings.Join(edit.Path, "."), err)
+		}
+	}
+
+	// Ensure the result is valid and has the expected values
+	var doc yaml.Node
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("edited document is not valid: %w", err)
+	}
+	for _, edit := range edits {
+		node := doc.Content[0]
+		for _, key := range edit.Path {
+			_, node = yamlMappingEntry(node, key)
+			if node == nil {
+				break
+			}
+		}
+		if node == nil || node.Value != edit.Value {
+			return nil, fmt.Errorf("edited document does not have the expected value for property '%s'", strings.Join(edit.Path, "."))
+		}
+	}
+
+	return data, nil
+}
+
+// yamlMappingEntry returns the key and value nodes for the key in the mapping, or nil if not found.
+func yamlMappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// replaceYamlScalar replaces the text of the scalar value with the new value, keeping its style when possible.
+func replaceYamlScalar(data []byte, key *yaml.Node, value *yaml.Node, newValue string) ([]byte, error) {
+	lines := yamlLineOffsets(data)
+	start, err := yamlNodeOffset(data, lines, value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find where the current value ends
+	var end int
+	switch {
+	case value.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		end = yamlBlockScalarEnd(data, lines, value.Line, key.Column-1)
+	case value.Style&yaml.DoubleQuotedStyle != 0:
+		end = yamlQuotedScalarEnd(data, start, '"')
+	case value.Style&yaml.SingleQuotedStyle != 0:
+		end = yamlQuotedScalarEnd(data, start, '\'')
+	default:
+		// Plain scalars end at the end of the line, or at a comment
+		end = start
+		for end < len(data) && data[end] != '\n' && !(data[end] == '#' && end > start && (data[end-1] == ' ' || data[end-1] == '\t')) {
+			end++
+		}
+		for end > start && (data[end-1] == ' ' || data[end-1] == '\t' || data[end-1] == '\r') {
+			end--
+		}
+	}
+	if end < 0 {
+		return nil, errors.New("could not find the end of the current value")
+	}
+
+	// Indentation for block scalars: keep the existing one, or use the key's plus 2
+	indent := strings.Repeat(" ", key.Column-1+2)
+	if value.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && value.Line < len(lines) {
+		line := data[lines[value.Line]:]
+		n := 0
+		for n < len(line) && line[n] == ' ' {
+			n++
+		}
+		if n > key.Column-1 {
+			indent = strings.Repeat(" ", n)
+		}
+	}
+
+	encoded, err := encodeYamlScalar(newValue, value.Style, indent)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, 0, len(data)-(end-start)+len(encoded))
+	res = append(res, data[:start]...)
+	res = append(res, encoded...)
+	res = append(res, data[end:]...)
+	return res, nil
+}
+
+// appendYamlMappingEntry adds a new key with the value at the end of the block mapping.
+func appendYamlMappingEntry(data []byte, mapping *yaml.Node, key string, value string) ([]byte, error) {
+	if len(mapping.Content) == 0 {
+		return nil, errors.New("cannot add properties to an empty mapping")
+	}
+
+	// The new entry is added after the last line that belongs to the mapping
+	// These are the line of the last key, and the following lines that are indented more than the keys
+	lines := yamlLineOffsets(data)
+	keyIndent := mapping.Content[0].Column - 1
+	lastLine := mapping.Content[len(mapping.Content)-2].Line
+	insertAt := len(data)
+	for l := lastLine - 1; l < len(lines); l++ {
+		line := data[lines[l]:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := bytes.TrimLeft(line, " ")
+		if len(bytes.TrimSpace(trimmed)) == 0 {
+			continue
+		}
+		if l >= lastLine && len(line)-len(trimmed) <= keyIndent {
+			break
+		}
+		insertAt = min(lines[l]+len(line)+1, len(data))
+	}
+
+	indent := strings.Repeat(" ", keyIndent)
+	encoded, err := encodeYamlScalar(value, 0, indent+"  ")
+	if err != nil {
+		return nil, err
+	}
+	entry := indent + key + ": " + encoded + "\n"
+
+	res := make([]byte, 0, len(data)+len(entry)+1)
+	res = append(res, data[:insertAt]...)
+	if insertAt > 0 && res[len(res)-1] != '\n' {
+		res = append(res, '\n')
+	}
+	res = append(res, entry...)
+	res = append(res, data[insertAt:]...)
+	return res, nil
+}
+
+// encodeYamlScalar returns the YAML representation of a string value.
+// Values with multiple lines, and values that were blocks, are encoded as literal blocks, with each line prefixed by indent.
+// Otherwise, the style is kept if possible.
+func encodeYamlScalar(value string, style yaml.Style, indent string) (string, error) {
+	if strings.Contains(value, "\n") || (style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && value != "") {
+		var sb strings.Builder
+		sb.WriteString("|")
+		if !strings.HasSuffix(value, "\n") {
+			sb.WriteString("-")
+		} else {
+			value = strings.TrimSuffix(value, "\n")
+		}
+		for _, line := range strings.Split(value, "\n") {
+			sb.WriteString("\n")
+			if line != "" {
+				sb.WriteString(indent + line)
+			}
+		}
+		return sb.String(), nil
+	}
+
+	// Let the encoder quote the value if needed
+	style &= yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+	out, err := yaml.Marshal(&yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!str",
+		Value: value,
+		Style: style,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// yamlLineOffsets returns the offset of the start of each line in data.
+func yamlLineOffsets(data []byte) []int {
+	res := []int{0}
+	for i, b := range data {
+		if b == '\n' && i+1 < len(data) {
+			res = append(res, i+1)
+		}
+	}
+	return res
+}
+
+// yamlNodeOffset returns the offset in data of the start of the node.
+func yamlNodeOffset(data []byte, lines []int, node *yaml.Node) (int, error) {
+	if node.Line < 1 || node.Line > len(lines) {
+		return 0, fmt.Errorf("invalid line %d", node.Line)
+	}
+
+	// Columns are counted in characters
+	offset := lines[node.Line-1]
+	for range node.Column - 1 {
+		if offset >= len(data) || data[offset] == '\n' {
+			return 0, fmt.Errorf("invalid column %d at line %d", node.Column, node.Line)
+		}
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+	return offset, nil
+}
+
+// yamlBlockScalarEnd returns the offset of the end of a block scalar that starts at the given line.
+// The scalar includes all following lines that are empty or indented more than parentIndent; trailing empty lines are excluded.
+func yamlBlockScalarEnd(data []byte, lines []int, startLine int, parentIndent int) int {
+	// End of the indicator line
+	end := bytes.IndexByte(data[lines[startLine-1]:], '\n')
+	if end < 0 {
+		return len(data)
+	}
+	end += lines[startLine-1]
+
+	for l := startLine; l < len(lines); l++ {
+		line := data[lines[l]:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := bytes.TrimLeft(line, " ")
+		if len(bytes.TrimSpace(trimmed)) == 0 {
+			// Empty lines are part of the scalar only if followed by more content
+			continue
+		}
+		if len(line)-len(trimmed) <= parentIndent {
+			break
+		}
+		end = lines[l] + len(line)
+	}
+	return end
+}
+
+// yamlQuotedScalarEnd returns the offset right after the closing quote of a quoted scalar that starts at the given offset, or -1 if not found.
+func yamlQuotedScalarEnd(data []byte, start int, quote byte) int {
+	for i := start + 1; i < len(data); i++ {
+		switch {
+		case quote == '"' && data[i] == '\\':
+			// Skip the escaped character
+			i++
+		case data[i] == quote && quote == '\'' && i+1 < len(data) && data[i+1] == '\'':
+			// Escaped single quote
+			i++
+		case data[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
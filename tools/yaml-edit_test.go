@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// If set, golden files are updated with the output of the tests
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+// checkGolden compares the result with the content of the golden file, updating it first if requested.
+func checkGolden(t *testing.T, goldenPath string, res []byte) {
+	t.Helper()
+	if *updateGolden {
+		err := os.WriteFile(goldenPath, res, 0o644)
+		if err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+	expected, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if string(res) != string(expected) {
+		t.Errorf("result does not match %s:\n%s", filepath.Base(goldenPath), res)
+	}
+}
+
+// The input files in testdata/yaml-edit are copies of files in the el10 folder
+func TestEditYamlGolden(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name   string
+		input  string
+		edits  []yamlEdit
+		golden string
+	}{
+		{
+			name:  "literal checksums block",
+			input: "restic-app.yaml",
+			edits: []yamlEdit{
+				{Path: []string{"version"}, Value: "0.20.0", Current: ptr("0.19.1")},
+				{Path: []string{"checksums"}, Value: "1111111111111111111111111111111111111111111111111111111111111111  restic_0.20.0_linux_amd64.bz2\n2222222222222222222222222222222222222222222222222222222222222222  restic_0.20.0_linux_arm64.bz2"},
+			},
+			golden: "restic-app.golden.yaml",
+		},
+		{
+			name:  "quoted scalars",
+			input: "config.yaml",
+			edits: []yamlEdit{
+				{Path: []string{"baseImages", "alma-linux-10", "tag"}, Value: "10.1", Current: ptr("10")},
+				{Path: []string{"baseImages", "alma-linux-10", "digest"}, Value: "sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+				{Path: []string{"baseImages", "centos-stream-10", "digest"}, Value: "sha256:2222222222222222222222222222222222222222222222222222222222222222"},
+			},
+			golden: "config.golden.yaml",
+		},
+		{
+			name:  "comments",
+			input: "cloudflared-app.yaml",
+			edits: []yamlEdit{
+				{Path: []string{"version"}, Value: "2026.9.0"},
+				{Path: []string{"checksums"}, Value: "1111111111111111111111111111111111111111111111111111111111111111  cloudflared-linux-aarch64.rpm\n2222222222222222222222222222222222222222222222222222222222222222  cloudflared-linux-x86_64.rpm"},
+			},
+			golden: "cloudflared-app.golden.yaml",
+		},
+		{
+			name:  "appended key",
+			input: "cloudflared-app.yaml",
+			edits: []yamlEdit{
+				{Path: []string{"source", "stripPrefix"}, Value: "v", Current: ptr("")},
+			},
+			golden: "cloudflared-app-append.golden.yaml",
+		},
+		{
+			name:  "plain scalar to block",
+			input: "gotop-app.yaml",
+			edits: []yamlEdit{
+				{Path: []string{"version"}, Value: "4.2.1"},
+				{Path: []string{"checksums"}, Value: "1111111111111111111111111111111111111111111111111111111111111111  gotop_v4.2.1_linux_amd64.rpm\n2222222222222222222222222222222222222222222222222222222222222222  gotop_v4.2.1_linux_arm64.rpm"},
+			},
+			golden: "gotop-app.golden.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := os.ReadFile(filepath.Join("testdata", "yaml-edit", tt.input))
+			if err != nil {
+				t.Fatalf("failed to read input: %v", err)
+			}
+
+			res, err := editYaml(input, tt.edits)
+			if err != nil {
+				t.Fatalf("failed to edit: %v", err)
+			}
+
+			checkGolden(t, filepath.Join("testdata", "yaml-edit", tt.golden), res)
+		})
+	}
+}
+
+// Lines that are not edited must be unchanged, byte for byte
+func TestEditYamlKeepsOtherLines(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "yaml-edit", "cloudflared-app.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read input: %v", err)
+	}
+
+	res, err := editYaml(input, []yamlEdit{
+		{Path: []string{"version"}, Value: "2026.9.0"},
+	})
+	if err != nil {
+		t.Fatalf("failed to edit: %v", err)
+	}
+
+	inLines := strings.Split(string(input), "\n")
+	outLines := strings.Split(string(res), "\n")
+	if len(inLines) != len(outLines) {
+		t.Fatalf("number of lines changed from %d to %d", len(inLines), len(outLines))
+	}
+	for i := range inLines {
+		if strings.HasPrefix(inLines[i], "version:") {
+			if outLines[i] != "version: 2026.9.0" {
+				t.Errorf("wrong edited line: %q", outLines[i])
+			}
+			continue
+		}
+		if inLines[i] != outLines[i] {
+			t.Errorf("line %d changed from %q to %q", i+1, inLines[i], outLines[i])
+		}
+	}
+}
+
+func TestEditYaml(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		input    string
+		edits    []yamlEdit
+		expected string
+		err      string
+	}{
+		{
+			name:     "inline comment",
+			input:    "version: 1.0.0 # pinned\nname: foo\n",
+			edits:    []yamlEdit{{Path: []string{"version"}, Value: "1.1.0"}},
+			expected: "version: 1.1.0 # pinned\nname: foo\n",
+		},
+		{
+			name:     "single quoted",
+			input:    "tag: '10'\n",
+			edits:    []yamlEdit{{Path: []string{"tag"}, Value: "it's"}},
+			expected: "tag: 'it''s'\n",
+		},
+		{
+			name:     "value that needs quoting",
+			input:    "version: 1.0\n",
+			edits:    []yamlEdit{{Path: []string{"version"}, Value: "10"}},
+			expected: "version: \"10\"\n",
+		},
+		{
+			name:     "appended key in nested mapping",
+			input:    "a:\n  b: x\n  c:\n    - 1\n# end\nd: y\n",
+			edits:    []yamlEdit{{Path: []string{"a", "e"}, Value: "z"}},
+			expected: "a:\n  b: x\n  c:\n    - 1\n  e: z\n# end\nd: y\n",
+		},
+		{
+			name:  "current value does not match",
+			input: "version: 1.0\n",
+			edits: []yamlEdit{{Path: []string{"version"}, Value: "1.2", Current: ptr("1.1")}},
+			err:   "property 'version' has value '1.0', but '1.1' was expected",
+		},
+		{
+			name:  "current value of a missing key does not match",
+			input: "version: 1.0\n",
+			edits: []yamlEdit{{Path: []string{"checksums"}, Value: "x", Current: ptr("y")}},
+			err:   "property 'checksums' has value '', but 'y' was expected",
+		},
+		{
+			name:  "missing parent",
+			input: "version: 1.0\n",
+			edits: []yamlEdit{{Path: []string{"source", "repo"}, Value: "x"}},
+			err:   "property 'source' not found",
+		},
+		{
+			name:  "not a scalar",
+			input: "apps:\n  - a\n",
+			edits: []yamlEdit{{Path: []string{"apps"}, Value: "x"}},
+			err:   "property 'apps' is not a scalar",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := editYaml([]byte(tt.input), tt.edits)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to edit: %v", err)
+			}
+			if string(res) != tt.expected {
+				t.Errorf("wrong result:\n%s\nexpected:\n%s", res, tt.expected)
+			}
+		})
+	}
+}
+
+// A rejected edit must not change the file
+func TestEditYamlFileRejected(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "yaml-edit", "restic-app.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read input: %v", err)
+	}
+	fileName := filepath.Join(t.TempDir(), "app.yaml")
+	err = os.WriteFile(fileName, input, 0o644)
+	if err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	current := "0.18.0"
+	err = editYamlFile(fileName, []yamlEdit{
+		{Path: []string{"checksums"}, Value: "x  restic"},
+		{Path: []string{"version"}, Value: "0.20.0", Current: &current},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	res, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(res) != string(input) {
+		t.Errorf("file was changed:\n%s", res)
+	}
+}
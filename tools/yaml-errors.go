@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlError is an error in a YAML file, at the given position.
+type yamlError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e yamlError) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			pos += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	return pos + ": " + e.Message
+}
+
+var (
+	// Errors returned by the YAML library include the line number only, at the beginning of the message
+	yamlErrorLineExp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	// Error returned for unknown fields in strict mode
+	yamlUnknownFieldExp = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+)
+
+// newYamlErrors converts an error returned by the YAML library into errors that include the file name and the position.
+// If node is not nil, it's used to find the column of the errors.
+func newYamlErrors(err error, fileName string, node *yaml.Node) error {
+	msgs := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	}
+
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		e := yamlError{File: fileName, Message: msg}
+
+		match := yamlErrorLineExp.FindStringSubmatch(msg)
+		if match != nil {
+			e.Line, _ = strconv.Atoi(match[1])
+			e.Message = match[2]
+			if node != nil {
+				e.Column = yamlNodeColumn(node, e.Line)
+			}
+		}
+
+		match = yamlUnknownFieldExp.FindStringSubmatch(e.Message)
+		if match != nil {
+			e.Message = "unknown property '" + match[1] + "' (use --strict-yaml=false to ignore unknown properties)"
+		}
+
+		errs[i] = e
+	}
+
+	return errors.Join(errs...)
+}
+
+// yamlNodeColumn returns the column of the first node at the given line, or 0 if there's none.
+func yamlNodeColumn(node *yaml.Node, line int) int {
+	if node.Line == line && node.Kind != yaml.DocumentNode {
+		return node.Column
+	}
+	for _, n := range node.Content {
+		col := yamlNodeColumn(n, line)
+		if col > 0 {
+			return col
+		}
+	}
+	return 0
+}
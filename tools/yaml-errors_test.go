@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeYamlErrorPositions(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		schema bool
+		errs   []string
+	}{
+		{
+			name:  "syntax error",
+			input: "name: tool\nversion: a: b\n",
+			errs:  []string{"app.yaml:2: mapping values are not allowed in this context"},
+		},
+		{
+			name:  "invalid character",
+			input: "name: tool\nversion: \"1\"\n\tfoo: 1\n",
+			errs:  []string{"app.yaml:3: found character that cannot start any token"},
+		},
+		{
+			name:  "empty file",
+			input: "",
+			errs:  []string{"app.yaml: file is empty"},
+		},
+		{
+			name:  "type error at the start of the line",
+			input: "name: tool\nbuilderContainerfiles: abc\n",
+			errs:  []string{"app.yaml:2:1: cannot unmarshal !!str `abc` into []string"},
+		},
+		{
+			name:  "unknown property",
+			input: "name: tool\nfoo: bar\n",
+			errs:  []string{"app.yaml:2:1: unknown property 'foo' (use --strict-yaml=false to ignore unknown properties)"},
+		},
+		{
+			name:  "nested errors",
+			input: "name: tool\nartifacts:\n  - name: x\n    nope: 1\nsource:\n  type: pypi\n  package: [a, b]\n",
+			errs: []string{
+				"app.yaml:4:5: unknown property 'nope' (use --strict-yaml=false to ignore unknown properties)",
+				"app.yaml:7:3: cannot unmarshal !!seq into string",
+			},
+		},
+		{
+			name:   "schema validation errors",
+			input:  "name: tool\nsource:\n  type: nope\nbuilderContainerfiles:\n  - a\n  - [b]\n",
+			schema: true,
+			errs: []string{
+				"app.yaml:3:9: property 'source.type': must be one of: github-release, pypi, rpm-repo, container-tag",
+				"app.yaml:6:5: property 'builderContainerfiles[1]': must be a string",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yamlValidateSchema = tt.schema
+			t.Cleanup(func() { yamlValidateSchema = false })
+
+			var app App
+			err := decodeYaml(&app, strings.NewReader(tt.input), "app.yaml")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, e := range tt.errs {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("error %q does not contain %q", err, e)
+				}
+			}
+
+			// Each position is reported as a separate error
+			var yamlErr yamlError
+			if !errors.As(err, &yamlErr) {
+				t.Errorf("error does not contain a yamlError: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadYamlFileErrorPosition(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"apps/tool/app.yaml": "name: tool\nversion: 1.0.0\nchecksums:\n  - abc\n",
+	})
+
+	var app App
+	fileName := filepath.Join(dir, "apps/tool/app.yaml")
+	err := loadYamlFile(&app, fileName)
+	expected := fileName + ":4:3: cannot unmarshal !!seq into string"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error containing %q, got %v", expected, err)
+	}
+}